@@ -0,0 +1,280 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// ErrAggregateNotFound is returned by Repository.Load for aggregates without events
+var ErrAggregateNotFound = errors.New("aggregate not found")
+
+// Aggregate is implemented by types embedding AggregateRoot
+type Aggregate interface {
+	Root() *AggregateRoot
+}
+
+// AggregateRoot tracks an event-sourced aggregate's ID, version and the
+// events applied since it was last saved. Embed it in aggregate types and
+// register an applier per event type with On.
+type AggregateRoot struct {
+	id          string
+	version     int
+	uncommitted []Event
+	// staged counts the leading uncommitted events saved in a unit of work
+	// that has not completed yet
+	staged   int
+	appliers map[reflect.Type]func(Event)
+}
+
+// On registers apply as the state transition for events of type E.
+func On[E Event](root *AggregateRoot, apply func(event E)) {
+	if root.appliers == nil {
+		root.appliers = map[reflect.Type]func(Event){}
+	}
+	typ := reflect.TypeFor[E]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	root.appliers[typ] = func(event Event) {
+		apply(event.(E))
+	}
+}
+
+// Root returns the aggregate root itself, so embedding types implement Aggregate.
+func (a *AggregateRoot) Root() *AggregateRoot {
+	return a
+}
+
+func (a *AggregateRoot) ID() string {
+	return a.id
+}
+
+// Version is the number of events applied, including uncommitted ones
+func (a *AggregateRoot) Version() int {
+	return a.version
+}
+
+// Uncommitted returns the events applied since the aggregate was loaded or saved
+func (a *AggregateRoot) Uncommitted() []Event {
+	return a.uncommitted
+}
+
+// Apply runs the event's applier and records it as uncommitted. The first
+// applied event sets the aggregate's ID if it has none.
+func (a *AggregateRoot) Apply(event Event) error {
+	if err := a.transition(event); err != nil {
+		return err
+	}
+	if a.id == "" {
+		a.id = event.AggregateID()
+	}
+	a.version++
+	a.uncommitted = append(a.uncommitted, event)
+	return nil
+}
+
+// replay applies a stored event without recording it
+func (a *AggregateRoot) replay(stored StoredEvent) error {
+	if err := a.transition(stored.Event); err != nil {
+		return err
+	}
+	a.version = stored.Version
+	return nil
+}
+
+func (a *AggregateRoot) transition(event Event) error {
+	apply, ok := a.appliers[eventKey(event)]
+	if !ok {
+		return fmt.Errorf("no applier for event type %T on aggregate %s", event, a.id)
+	}
+	apply(event)
+	return nil
+}
+
+// committedVersion is the version the aggregate had when loaded or last saved
+func (a *AggregateRoot) committedVersion() int {
+	return a.version - len(a.uncommitted)
+}
+
+// ###
+
+// Repository loads aggregates by replaying their events from an EventStore
+// and saves them by emitting their uncommitted events.
+type Repository[T Aggregate] struct {
+	store          EventStore
+	emitter        *EventEmitter
+	factory        func(id string) T
+	snapshots      SnapshotStore
+	snapshotPolicy SnapshotPolicy
+	clock          Clock
+}
+
+// NewRepository creates a repository. factory must return an empty aggregate
+// with its appliers registered. For optimistic concurrency on Save, the
+// emitter's LogWriter should be an EventAppender such as an EventStore.
+func NewRepository[T Aggregate](store EventStore, emitter *EventEmitter, factory func(id string) T) *Repository[T] {
+	return &Repository[T]{
+		store:   store,
+		emitter: emitter,
+		factory: factory,
+	}
+}
+
+// SetSnapshots enables snapshots for aggregates implementing Snapshotter.
+// policy decides when Save takes a snapshot; nil means only on demand through Snapshot.
+func (r *Repository[T]) SetSnapshots(store SnapshotStore, policy SnapshotPolicy) {
+	r.snapshots = store
+	r.snapshotPolicy = policy
+}
+
+// SetClock sets the clock that timestamps snapshots, DefaultClock when nil
+func (r *Repository[T]) SetClock(c Clock) {
+	r.clock = c
+}
+
+// Load rehydrates the aggregate from its latest usable snapshot, if any,
+// and the events after it.
+func (r *Repository[T]) Load(ctx context.Context, id string) (T, error) {
+	aggregate := r.factory(id)
+	root := aggregate.Root()
+	root.id = id
+
+	restored, err := r.restore(ctx, aggregate)
+	if err != nil {
+		return aggregate, err
+	}
+	if !restored {
+		// A failed restore may have left partial state behind
+		aggregate = r.factory(id)
+		root = aggregate.Root()
+		root.id = id
+	}
+
+	stored, err := r.store.Load(ctx, id, root.version+1)
+	if err != nil {
+		return aggregate, err
+	}
+	if len(stored) == 0 && root.version == 0 {
+		return aggregate, fmt.Errorf("%w: %s", ErrAggregateNotFound, id)
+	}
+
+	for _, s := range stored {
+		if err := root.replay(s); err != nil {
+			return aggregate, err
+		}
+	}
+	return aggregate, nil
+}
+
+// Save emits the aggregate's uncommitted events, expecting the aggregate to
+// still be at the version it was loaded at, and marks them committed.
+//
+// Once the events are stored they are committed even if delivering them to
+// subscribers fails; Save then returns the *DeliveryError, and saving again
+// does nothing.
+//
+// Within a UnitOfWork the events are stored when the command succeeds, and
+// only then committed and snapshotted. If the command fails they stay
+// uncommitted.
+func (r *Repository[T]) Save(ctx context.Context, aggregate T) error {
+	root := aggregate.Root()
+	events := root.uncommitted[root.staged:]
+	if len(events) == 0 {
+		return nil
+	}
+	fromVersion := root.committedVersion() + root.staged
+
+	if unit := unitFromContext(ctx); unit != nil {
+		entry := unitEntry{
+			emit: func() error {
+				root.staged -= len(events)
+				return r.save(withoutUnit(ctx), aggregate, fromVersion, events)
+			},
+			discard: func() { root.staged -= len(events) },
+		}
+		if unit.buffer(entry) {
+			root.staged += len(events)
+			return nil
+		}
+	}
+	if root.staged > 0 {
+		return fmt.Errorf("aggregate %s has events waiting for a unit of work", root.id)
+	}
+	return r.save(ctx, aggregate, fromVersion, events)
+}
+
+// save emits events, the oldest uncommitted ones of aggregate, and commits
+// them once stored
+func (r *Repository[T]) save(ctx context.Context, aggregate T, fromVersion int, events []Event) error {
+	root := aggregate.Root()
+	err := r.emitter.EmitVersioned(ctx, root.id, fromVersion, events...)
+	var delivery *DeliveryError
+	if err != nil && !errors.As(err, &delivery) {
+		return err
+	}
+	root.uncommitted = root.uncommitted[len(events):]
+	if len(root.uncommitted) == 0 {
+		root.uncommitted = nil
+	}
+
+	// The events are committed, so a failed snapshot only costs replay time
+	// later. Events still waiting to be saved make the state uncommitted.
+	if len(root.uncommitted) == 0 && r.snapshotPolicy != nil && r.snapshotPolicy(root, fromVersion) {
+		if err := r.Snapshot(ctx, aggregate); err != nil {
+			log.Printf("snapshot of aggregate %s failed: %v", root.id, err)
+		}
+	}
+	return err
+}
+
+// Snapshot stores the aggregate's committed state. It does nothing unless
+// snapshots are enabled and the aggregate implements Snapshotter.
+func (r *Repository[T]) Snapshot(ctx context.Context, aggregate T) error {
+	snapshotter, ok := any(aggregate).(Snapshotter)
+	if !ok || r.snapshots == nil {
+		return nil
+	}
+	root := aggregate.Root()
+	if len(root.uncommitted) > 0 {
+		return fmt.Errorf("aggregate %s has uncommitted events", root.id)
+	}
+
+	data, err := snapshotter.MarshalSnapshot()
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+	return r.snapshots.SaveSnapshot(ctx, Snapshot{
+		AggregateID:   root.id,
+		Version:       root.version,
+		SchemaVersion: snapshotter.SnapshotVersion(),
+		Data:          data,
+		Time:          clockOrDefault(r.clock).Now().UTC(),
+	})
+}
+
+// restore applies the latest snapshot to aggregate, reporting whether it
+// did so. Missing, outdated and unreadable snapshots fall back to full replay.
+func (r *Repository[T]) restore(ctx context.Context, aggregate T) (bool, error) {
+	snapshotter, ok := any(aggregate).(Snapshotter)
+	if !ok || r.snapshots == nil {
+		return false, nil
+	}
+	root := aggregate.Root()
+
+	snapshot, err := r.snapshots.LoadSnapshot(ctx, root.id)
+	if err != nil {
+		return false, fmt.Errorf("load snapshot: %w", err)
+	}
+	if snapshot == nil || snapshot.SchemaVersion != snapshotter.SnapshotVersion() {
+		return false, nil
+	}
+	if err := snapshotter.UnmarshalSnapshot(snapshot.Data); err != nil {
+		log.Printf("ignoring unreadable snapshot of aggregate %s: %v", root.id, err)
+		return false, nil
+	}
+	root.version = snapshot.Version
+	return true, nil
+}
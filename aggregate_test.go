@@ -0,0 +1,236 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type AccountOpenedEvent struct {
+	BaseEvent
+	Owner string `json:"owner"`
+}
+
+type MoneyDepositedEvent struct {
+	BaseEvent
+	Amount int `json:"amount"`
+}
+
+type Account struct {
+	AggregateRoot
+	Owner   string
+	Balance int
+}
+
+func NewAccount(id string) *Account {
+	a := &Account{}
+	On(a.Root(), func(e *AccountOpenedEvent) {
+		a.Owner = e.Owner
+	})
+	On(a.Root(), func(e *MoneyDepositedEvent) {
+		a.Balance += e.Amount
+	})
+	return a
+}
+
+func (a *Account) Open(id, owner string) error {
+	return a.Apply(&AccountOpenedEvent{BaseEvent: NewBaseEvent("AccountOpened", id, 1), Owner: owner})
+}
+
+func (a *Account) Deposit(amount int) error {
+	return a.Apply(&MoneyDepositedEvent{BaseEvent: NewBaseEvent("MoneyDeposited", a.ID(), 1), Amount: amount})
+}
+
+func newAccountRepository() (*Repository[*Account], *InMemoryEventStore, *InMemoryDispatcher) {
+	store := NewInMemoryEventStore()
+	dispatcher := NewInMemoryDispatcher()
+	emitter := NewEventEmitter(NewEventStoreWriter(store), dispatcher)
+	return NewRepository(store, emitter, NewAccount), store, dispatcher
+}
+
+func TestAggregateRoot_Apply(t *testing.T) {
+	t.Run("applies events and tracks them as uncommitted", func(t *testing.T) {
+		account := NewAccount("")
+		if err := account.Open("acc-1", "John"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := account.Deposit(50); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if account.ID() != "acc-1" || account.Owner != "John" || account.Balance != 50 {
+			t.Errorf("expected acc-1 owned by John with 50, got %s %s %d", account.ID(), account.Owner, account.Balance)
+		}
+
+		if account.Version() != 2 || len(account.Uncommitted()) != 2 {
+			t.Errorf("expected version 2 with 2 uncommitted events, got %d and %d", account.Version(), len(account.Uncommitted()))
+		}
+	})
+
+	t.Run("rejects events without applier", func(t *testing.T) {
+		account := NewAccount("")
+		err := account.Apply(NewUserCreatedEvent("acc-1", "John"))
+
+		if err == nil {
+			t.Fatal("expected error for event without applier, got nil")
+		}
+
+		if account.Version() != 0 {
+			t.Errorf("expected version 0, got %d", account.Version())
+		}
+	})
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("saves and rehydrates an aggregate", func(t *testing.T) {
+		repo, store, dispatcher := newAccountRepository()
+		var dispatched int
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			dispatched++
+			return nil, nil
+		})
+
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+		account.Deposit(50)
+		account.Deposit(25)
+
+		if err := repo.Save(ctx, account); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(account.Uncommitted()) != 0 {
+			t.Errorf("expected no uncommitted events after save, got %d", len(account.Uncommitted()))
+		}
+
+		if stored, _ := store.Load(ctx, "acc-1", 1); len(stored) != 3 || dispatched != 3 {
+			t.Errorf("expected 3 stored and 3 dispatched events, got %d and %d", len(stored), dispatched)
+		}
+
+		loaded, err := repo.Load(ctx, "acc-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if loaded.Owner != "John" || loaded.Balance != 75 || loaded.Version() != 3 {
+			t.Errorf("expected John with 75 at version 3, got %s with %d at version %d", loaded.Owner, loaded.Balance, loaded.Version())
+		}
+	})
+
+	t.Run("appends to a loaded aggregate", func(t *testing.T) {
+		repo, _, _ := newAccountRepository()
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+		repo.Save(ctx, account)
+
+		loaded, _ := repo.Load(ctx, "acc-1")
+		loaded.Deposit(10)
+		if err := repo.Save(ctx, loaded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		reloaded, _ := repo.Load(ctx, "acc-1")
+		if reloaded.Balance != 10 || reloaded.Version() != 2 {
+			t.Errorf("expected 10 at version 2, got %d at version %d", reloaded.Balance, reloaded.Version())
+		}
+	})
+
+	t.Run("rejects saving a stale aggregate", func(t *testing.T) {
+		repo, _, dispatcher := newAccountRepository()
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+		repo.Save(ctx, account)
+
+		first, _ := repo.Load(ctx, "acc-1")
+		second, _ := repo.Load(ctx, "acc-1")
+		first.Deposit(10)
+		second.Deposit(20)
+		repo.Save(ctx, first)
+
+		var dispatched int
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			dispatched++
+			return nil, nil
+		})
+
+		err := repo.Save(ctx, second)
+		if !errors.Is(err, ErrConcurrencyConflict) {
+			t.Errorf("expected ErrConcurrencyConflict, got %v", err)
+		}
+
+		if dispatched != 0 {
+			t.Errorf("expected rejected events not to be dispatched, got %d", dispatched)
+		}
+	})
+
+	t.Run("commits stored events whose delivery failed", func(t *testing.T) {
+		repo, store, dispatcher := newAccountRepository()
+		errHandler := errors.New("handler failed")
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			return nil, errHandler
+		})
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+
+		err := repo.Save(ctx, account)
+		var delivery *DeliveryError
+		if !errors.As(err, &delivery) || !errors.Is(err, errHandler) {
+			t.Fatalf("expected DeliveryError wrapping %v, got %v", errHandler, err)
+		}
+		if len(account.Root().uncommitted) != 0 {
+			t.Error("expected stored events to be committed")
+		}
+
+		// Saving again must not append the stored events a second time
+		if err := repo.Save(ctx, account); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stored, _ := store.Load(ctx, "acc-1", 1); len(stored) != 1 {
+			t.Errorf("expected 1 stored event, got %d", len(stored))
+		}
+	})
+
+	t.Run("keeps events that were not stored", func(t *testing.T) {
+		repo, _, _ := newAccountRepository()
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+		stale := NewAccount("")
+		stale.Open("acc-1", "Jane")
+		repo.Save(ctx, account)
+
+		err := repo.Save(ctx, stale)
+		var delivery *DeliveryError
+		if err == nil || errors.As(err, &delivery) {
+			t.Fatalf("expected a storage error, got %v", err)
+		}
+		if len(stale.Root().uncommitted) != 1 {
+			t.Error("expected unstored events to stay uncommitted")
+		}
+	})
+
+	t.Run("reports missing aggregate", func(t *testing.T) {
+		repo, _, _ := newAccountRepository()
+
+		if _, err := repo.Load(ctx, "missing"); !errors.Is(err, ErrAggregateNotFound) {
+			t.Errorf("expected ErrAggregateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("writes each event with a plain log writer", func(t *testing.T) {
+		logWriter := &recordingLogWriter{}
+		repo := NewRepository(NewInMemoryEventStore(), NewEventEmitter(logWriter, NewInMemoryDispatcher()), NewAccount)
+		account := NewAccount("")
+		account.Open("acc-1", "John")
+		account.Deposit(5)
+
+		if err := repo.Save(ctx, account); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(logWriter.events) != 2 {
+			t.Errorf("expected 2 written events, got %d", len(logWriter.events))
+		}
+	})
+}
@@ -2,8 +2,14 @@ package gocmdevt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type Command interface{}
@@ -13,32 +19,254 @@ type Module interface {
 	Handlers() map[reflect.Type]HandlerFunc
 }
 
+// NamedModule is an optional interface for modules that want a readable
+// name in handler conflict reports. Other modules are named by their type.
+type NamedModule interface {
+	Name() string
+}
+
+// ConflictPolicy decides what happens when a command type is registered twice
+type ConflictPolicy int
+
+const (
+	// ConflictLastWins replaces the existing handler and logs a warning
+	ConflictLastWins ConflictPolicy = iota
+	// ConflictFirstWins keeps the existing handler and ignores the new one
+	ConflictFirstWins
+	// ConflictReturnError rejects the whole registration with a *DuplicateHandlerError
+	ConflictReturnError
+	// ConflictPanic panics with a *DuplicateHandlerError
+	ConflictPanic
+)
+
+// ErrDuplicateHandler is matched by every *DuplicateHandlerError
+var ErrDuplicateHandler = errors.New("duplicate command handler")
+
+// HandlerConflict describes one command type claimed by two registrations
+type HandlerConflict struct {
+	CommandType reflect.Type
+	Existing    string
+	Incoming    string
+}
+
+func (c HandlerConflict) String() string {
+	return fmt.Sprintf("%v registered by %s and %s", c.CommandType, c.Existing, c.Incoming)
+}
+
+// DuplicateHandlerError reports every conflict found in a single registration
+type DuplicateHandlerError struct {
+	Conflicts []HandlerConflict
+}
+
+func (e *DuplicateHandlerError) Error() string {
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = c.String()
+	}
+	return fmt.Sprintf("%v: %s", ErrDuplicateHandler, strings.Join(parts, "; "))
+}
+
+func (e *DuplicateHandlerError) Unwrap() error {
+	return ErrDuplicateHandler
+}
+
+// App routes commands to handlers registered by modules. It is safe for
+// concurrent use: registration copies the handler table and swaps it in
+// atomically, so Handle never takes a lock.
 type App struct {
-	handlers map[reflect.Type]HandlerFunc
+	mu             sync.Mutex // serializes writers
+	state          atomic.Pointer[appState]
+	conflictPolicy ConflictPolicy
+}
+
+// appState is an immutable snapshot of the app's registrations
+type appState struct {
+	handlers        map[reflect.Type]HandlerFunc
+	owners          map[reflect.Type]string
+	middlewares     []Middleware
+	typeMiddlewares map[reflect.Type][]Middleware
+	// chains holds each handler already wrapped with its middleware
+	chains map[reflect.Type]HandlerFunc
 }
 
+var emptyAppState = &appState{
+	handlers: map[reflect.Type]HandlerFunc{},
+	chains:   map[reflect.Type]HandlerFunc{},
+}
+
+// NewApp creates an app with the given modules using the ConflictLastWins policy,
+// so modules claiming the same command are only reported in the log. Use
+// NewAppWithPolicy to reject them.
 func NewApp(modules ...Module) *App {
-	app := &App{
-		handlers: map[reflect.Type]HandlerFunc{},
+	app := &App{}
+	app.state.Store(emptyAppState)
+	for _, m := range modules {
+		app.RegisterModule(m)
 	}
+	return app
+}
+
+// NewAppWithPolicy creates an app that registers the given modules, in order,
+// under policy. Under ConflictReturnError the first rejected module stops the
+// construction and its *DuplicateHandlerError is returned; under ConflictPanic
+// it panics.
+func NewAppWithPolicy(policy ConflictPolicy, modules ...Module) (*App, error) {
+	app := &App{conflictPolicy: policy}
+	app.state.Store(emptyAppState)
 	for _, m := range modules {
-		for typ, h := range m.Handlers() {
-			app.handlers[typ] = h
+		if err := app.TryRegisterModule(m); err != nil {
+			return nil, err
 		}
 	}
-	return app
+	return app, nil
 }
 
+// snapshot returns the current registrations; it must not be mutated
+func (a *App) snapshot() *appState {
+	if s := a.state.Load(); s != nil {
+		return s
+	}
+	return emptyAppState
+}
+
+// update applies fn to a copy of the current state and publishes it unless fn fails
+func (a *App) update(fn func(s *appState) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cur := a.snapshot()
+	next := &appState{
+		handlers:        make(map[reflect.Type]HandlerFunc, len(cur.handlers)),
+		owners:          make(map[reflect.Type]string, len(cur.owners)),
+		middlewares:     cur.middlewares[:len(cur.middlewares):len(cur.middlewares)],
+		typeMiddlewares: make(map[reflect.Type][]Middleware, len(cur.typeMiddlewares)),
+	}
+	for typ, h := range cur.handlers {
+		next.handlers[typ] = h
+	}
+	for typ, owner := range cur.owners {
+		next.owners[typ] = owner
+	}
+	for typ, mws := range cur.typeMiddlewares {
+		next.typeMiddlewares[typ] = mws[:len(mws):len(mws)]
+	}
+
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	next.chains = make(map[reflect.Type]HandlerFunc, len(next.handlers))
+	for typ, h := range next.handlers {
+		if mws := next.typeMiddlewares[typ]; len(mws) > 0 {
+			h = Chain(mws...)(h)
+		}
+		if len(next.middlewares) > 0 {
+			h = Chain(next.middlewares...)(h)
+		}
+		next.chains[typ] = h
+	}
+	a.state.Store(next)
+	return nil
+}
+
+// SetConflictPolicy sets how later registrations treat already registered command types.
+func (a *App) SetConflictPolicy(policy ConflictPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conflictPolicy = policy
+}
+
+// RegisterModule adds the module's handlers to the app. It has no error to
+// return a rejected module with, so under ConflictReturnError it panics with
+// the *DuplicateHandlerError rather than silently dropping the module; use
+// TryRegisterModule to handle the error instead.
 func (a *App) RegisterModule(module Module) {
-	for typ, handler := range module.Handlers() {
-		a.handlers[typ] = handler
+	if err := a.TryRegisterModule(module); err != nil {
+		panic(err)
 	}
 }
 
+// TryRegisterModule adds the module's handlers to the app, applying the
+// conflict policy and returning a *DuplicateHandlerError on rejected conflicts.
+func (a *App) TryRegisterModule(module Module) error {
+	return a.register(ModuleName(module), moduleHandlers(module))
+}
+
+// ModuleName returns the module's Name if it implements NamedModule, or its type otherwise.
+func ModuleName(module Module) string {
+	if named, ok := module.(NamedModule); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", module)
+}
+
+func (a *App) register(owner string, handlers map[reflect.Type]HandlerFunc) error {
+	var conflictErr *DuplicateHandlerError
+	err := a.update(func(s *appState) error {
+		var conflicts []HandlerConflict
+		for typ := range handlers {
+			if _, exists := s.handlers[typ]; exists {
+				conflicts = append(conflicts, HandlerConflict{
+					CommandType: typ,
+					Existing:    s.owners[typ],
+					Incoming:    owner,
+				})
+			}
+		}
+		sort.Slice(conflicts, func(i, j int) bool {
+			return conflicts[i].CommandType.String() < conflicts[j].CommandType.String()
+		})
+
+		if len(conflicts) > 0 {
+			switch a.conflictPolicy {
+			case ConflictReturnError:
+				return &DuplicateHandlerError{Conflicts: conflicts}
+			case ConflictPanic:
+				conflictErr = &DuplicateHandlerError{Conflicts: conflicts}
+				return conflictErr
+			case ConflictLastWins:
+				for _, c := range conflicts {
+					log.Printf("handler conflict: %v; using %s", c, c.Incoming)
+				}
+			}
+		}
+
+		for typ, h := range handlers {
+			if _, exists := s.handlers[typ]; exists && a.conflictPolicy == ConflictFirstWins {
+				continue
+			}
+			s.handlers[typ] = h
+			s.owners[typ] = owner
+		}
+		return nil
+	})
+	// Panic outside update so the writer lock is released first
+	if conflictErr != nil {
+		panic(conflictErr)
+	}
+	return err
+}
+
+// Handle runs the handler registered for cmd. A CommandEnvelope is handled
+// by the handler of the command it wraps.
+//
+// The metadata of a MetadataCommand or CommandEnvelope is available to the
+// handler through CommandMetadataFromContext, and the command's ID becomes
+// the causation ID of the events it emits. A command outside any chain
+// starts a new one, correlated by its ID.
 func (a *App) Handle(ctx context.Context, cmd Command) (any, error) {
-	handler, ok := a.handlers[reflect.TypeOf(cmd)]
+	cmd, meta, hasMeta := unwrapCommand(cmd)
+	handler, ok := a.snapshot().chains[reflect.TypeOf(cmd)]
 	if !ok {
 		return nil, fmt.Errorf("no handler for command type: %T", cmd)
 	}
+
+	switch {
+	case hasMeta:
+		ctx = withCommandMetadata(ctx, meta)
+	case CorrelationIDFromContext(ctx) == "":
+		id := generateEventID()
+		ctx = withCause(ctx, id, id)
+	}
 	return handler(ctx, cmd)
 }
@@ -2,9 +2,11 @@ package gocmdevt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -28,6 +30,11 @@ type contextKey string
 
 const testContextKey contextKey = "test"
 
+// setHandler registers h for typ, replacing any existing handler
+func setHandler(app *App, typ reflect.Type, h HandlerFunc) {
+	app.register("test", map[reflect.Type]HandlerFunc{typ: h})
+}
+
 // Simple module implementations
 type UserModule struct{}
 
@@ -93,12 +100,12 @@ func TestNewApp(t *testing.T) {
 			t.Fatal("expected app to be created, got nil")
 		}
 
-		if app.handlers == nil {
+		if app.snapshot().handlers == nil {
 			t.Fatal("expected handlers map to be initialized")
 		}
 
-		if len(app.handlers) != 0 {
-			t.Errorf("expected empty handlers map, got %d handlers", len(app.handlers))
+		if len(app.snapshot().handlers) != 0 {
+			t.Errorf("expected empty handlers map, got %d handlers", len(app.snapshot().handlers))
 		}
 	})
 
@@ -111,19 +118,19 @@ func TestNewApp(t *testing.T) {
 		}
 
 		expectedHandlers := 2 // CreateUserCommand and DeleteUserCommand
-		if len(app.handlers) != expectedHandlers {
-			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.handlers))
+		if len(app.snapshot().handlers) != expectedHandlers {
+			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.snapshot().handlers))
 		}
 
 		// Check that specific command types are registered
 		createUserType := reflect.TypeOf(&CreateUserCommand{})
 		deleteUserType := reflect.TypeOf(&DeleteUserCommand{})
 
-		if _, exists := app.handlers[createUserType]; !exists {
+		if _, exists := app.snapshot().handlers[createUserType]; !exists {
 			t.Error("expected CreateUserCommand handler to be registered")
 		}
 
-		if _, exists := app.handlers[deleteUserType]; !exists {
+		if _, exists := app.snapshot().handlers[deleteUserType]; !exists {
 			t.Error("expected DeleteUserCommand handler to be registered")
 		}
 	})
@@ -134,8 +141,8 @@ func TestNewApp(t *testing.T) {
 		app := NewApp(userModule, adminModule)
 
 		expectedHandlers := 3 // CreateUser, DeleteUser, UpdateUser
-		if len(app.handlers) != expectedHandlers {
-			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.handlers))
+		if len(app.snapshot().handlers) != expectedHandlers {
+			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.snapshot().handlers))
 		}
 
 		// Check all command types are registered
@@ -146,7 +153,7 @@ func TestNewApp(t *testing.T) {
 		}
 
 		for _, typ := range types {
-			if _, exists := app.handlers[typ]; !exists {
+			if _, exists := app.snapshot().handlers[typ]; !exists {
 				t.Errorf("expected handler for type %v to be registered", typ)
 			}
 		}
@@ -158,25 +165,25 @@ func TestNewApp(t *testing.T) {
 		app := NewApp(emptyModule, userModule)
 
 		expectedHandlers := 2 // Only from UserModule
-		if len(app.handlers) != expectedHandlers {
-			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.handlers))
+		if len(app.snapshot().handlers) != expectedHandlers {
+			t.Errorf("expected %d handlers, got %d", expectedHandlers, len(app.snapshot().handlers))
 		}
 	})
 
 	t.Run("later modules override earlier modules for same command type", func(t *testing.T) {
 		// Create a custom app to test override behavior
-		app := &App{handlers: map[reflect.Type]HandlerFunc{}}
+		app := &App{}
 
 		userModule := NewUserModule()
 		// Add first module handlers
 		for typ, h := range userModule.Handlers() {
-			app.handlers[typ] = h
+			setHandler(app, typ, h)
 		}
 
 		// Override the CreateUserCommand handler
-		app.handlers[reflect.TypeOf(&CreateUserCommand{})] = func(ctx context.Context, cmd Command) (any, error) {
+		setHandler(app, reflect.TypeOf(&CreateUserCommand{}), func(ctx context.Context, cmd Command) (any, error) {
 			return "overridden", nil
-		}
+		})
 
 		// Test that the overridden handler is used
 		ctx := context.Background()
@@ -329,10 +336,10 @@ func TestApp_Handle(t *testing.T) {
 
 	t.Run("preserves context in handler", func(t *testing.T) {
 		// Create an app with a custom handler that uses context
-		app := &App{handlers: map[reflect.Type]HandlerFunc{}}
+		app := &App{}
 
 		// Add a handler that checks context
-		app.handlers[reflect.TypeOf(CreateUserCommand{})] = func(ctx context.Context, cmd Command) (any, error) {
+		setHandler(app, reflect.TypeOf(CreateUserCommand{}), func(ctx context.Context, cmd Command) (any, error) {
 			if ctx == nil {
 				return nil, fmt.Errorf("context is nil")
 			}
@@ -347,7 +354,7 @@ func TestApp_Handle(t *testing.T) {
 			return map[string]interface{}{
 				"contextReceived": true,
 			}, nil
-		}
+		})
 
 		// Test with context containing a value
 		ctx := context.WithValue(context.Background(), testContextKey, "contextData")
@@ -389,12 +396,12 @@ func TestApp_Handle_EdgeCases(t *testing.T) {
 	})
 
 	t.Run("handles pointer command types", func(t *testing.T) {
-		app := &App{handlers: map[reflect.Type]HandlerFunc{}}
+		app := &App{}
 
 		// Register handler for pointer type
-		app.handlers[reflect.TypeOf(&CreateUserCommand{})] = func(ctx context.Context, cmd Command) (any, error) {
+		setHandler(app, reflect.TypeOf(&CreateUserCommand{}), func(ctx context.Context, cmd Command) (any, error) {
 			return "pointer command handled", nil
-		}
+		})
 
 		ctx := context.Background()
 		cmd := &CreateUserCommand{Name: "Test", Email: "test@example.com"}
@@ -411,6 +418,201 @@ func TestApp_Handle_EdgeCases(t *testing.T) {
 	})
 }
 
+type namedUserModule struct {
+	*UserModule
+	name string
+}
+
+func (m *namedUserModule) Name() string {
+	return m.name
+}
+
+func TestApp_ConflictPolicy(t *testing.T) {
+	ctx := context.Background()
+	overriding := moduleFunc(func() map[reflect.Type]HandlerFunc {
+		return map[reflect.Type]HandlerFunc{
+			reflect.TypeOf(&CreateUserCommand{}): func(ctx context.Context, cmd Command) (any, error) {
+				return "second", nil
+			},
+		}
+	})
+
+	t.Run("last wins by default", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+
+		if err := app.TryRegisterModule(overriding); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result, _ := app.Handle(ctx, &CreateUserCommand{})
+		if result != "second" {
+			t.Errorf("expected 'second', got %v", result)
+		}
+	})
+
+	t.Run("first wins keeps existing handler", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+		app.SetConflictPolicy(ConflictFirstWins)
+
+		if err := app.TryRegisterModule(overriding); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result, _ := app.Handle(ctx, &CreateUserCommand{Name: "John"})
+		if _, ok := result.(map[string]interface{}); !ok {
+			t.Errorf("expected original handler result, got %v", result)
+		}
+	})
+
+	t.Run("return error reports colliding modules", func(t *testing.T) {
+		app := NewApp()
+		app.SetConflictPolicy(ConflictReturnError)
+
+		if err := app.TryRegisterModule(&namedUserModule{NewUserModule(), "users"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		err := app.TryRegisterModule(&namedUserModule{NewUserModule(), "accounts"})
+		if !errors.Is(err, ErrDuplicateHandler) {
+			t.Fatalf("expected ErrDuplicateHandler, got %v", err)
+		}
+
+		var dupErr *DuplicateHandlerError
+		if !errors.As(err, &dupErr) {
+			t.Fatalf("expected *DuplicateHandlerError, got %T", err)
+		}
+
+		if len(dupErr.Conflicts) != 2 {
+			t.Fatalf("expected 2 conflicts, got %d", len(dupErr.Conflicts))
+		}
+
+		for _, c := range dupErr.Conflicts {
+			if c.Existing != "users" || c.Incoming != "accounts" {
+				t.Errorf("expected conflict between users and accounts, got %v", c)
+			}
+		}
+	})
+
+	t.Run("return error registers nothing from rejected module", func(t *testing.T) {
+		app := NewApp(NewAdminModule())
+		app.SetConflictPolicy(ConflictReturnError)
+
+		mixed := moduleFunc(func() map[reflect.Type]HandlerFunc {
+			return map[reflect.Type]HandlerFunc{
+				reflect.TypeOf(&UpdateUserCommand{}): NewAdminModule().updateUser,
+				reflect.TypeOf(&DeleteUserCommand{}): NewUserModule().deleteUser,
+			}
+		})
+
+		if err := app.TryRegisterModule(mixed); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+
+		if _, exists := app.snapshot().handlers[reflect.TypeOf(&DeleteUserCommand{})]; exists {
+			t.Error("expected DeleteUserCommand handler not to be registered")
+		}
+	})
+
+	t.Run("panic policy panics with conflict error", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+		app.SetConflictPolicy(ConflictPanic)
+
+		defer func() {
+			r := recover()
+			if _, ok := r.(*DuplicateHandlerError); !ok {
+				t.Errorf("expected *DuplicateHandlerError panic, got %v", r)
+			}
+		}()
+
+		app.RegisterModule(NewUserModule())
+	})
+
+	t.Run("RegisterModule panics instead of dropping a rejected module", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+		app.SetConflictPolicy(ConflictReturnError)
+
+		defer func() {
+			if _, ok := recover().(*DuplicateHandlerError); !ok {
+				t.Error("expected *DuplicateHandlerError panic")
+			}
+		}()
+
+		app.RegisterModule(NewUserModule())
+	})
+
+	t.Run("NewAppWithPolicy returns conflicts between its modules", func(t *testing.T) {
+		app, err := NewAppWithPolicy(ConflictReturnError,
+			&namedUserModule{NewUserModule(), "users"},
+			&namedUserModule{NewUserModule(), "accounts"})
+
+		var dupErr *DuplicateHandlerError
+		if !errors.As(err, &dupErr) || app != nil {
+			t.Fatalf("expected *DuplicateHandlerError and no app, got %v and %v", err, app)
+		}
+		if dupErr.Conflicts[0].Existing != "users" || dupErr.Conflicts[0].Incoming != "accounts" {
+			t.Errorf("expected conflict between users and accounts, got %v", dupErr.Conflicts[0])
+		}
+	})
+
+	t.Run("NewAppWithPolicy applies the policy to later registrations", func(t *testing.T) {
+		app, err := NewAppWithPolicy(ConflictReturnError, NewUserModule())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := app.TryRegisterModule(overriding); !errors.Is(err, ErrDuplicateHandler) {
+			t.Errorf("expected ErrDuplicateHandler, got %v", err)
+		}
+	})
+
+	t.Run("typed registration reports conflicts", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+		app.SetConflictPolicy(ConflictReturnError)
+
+		err := Register(app, func(ctx context.Context, cmd *CreateUserCommand) (any, error) {
+			return nil, nil
+		})
+		if !errors.Is(err, ErrDuplicateHandler) {
+			t.Errorf("expected ErrDuplicateHandler, got %v", err)
+		}
+
+		if !strings.Contains(err.Error(), "*gocmdevt.UserModule") {
+			t.Errorf("expected error to name existing module, got '%s'", err.Error())
+		}
+	})
+}
+
+// Run with -race to verify the copy-on-write handler table
+func TestApp_Concurrent(t *testing.T) {
+	app := NewApp(NewUserModule())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				app.RegisterModule(NewAdminModule())
+				app.Use(func(next HandlerFunc) HandlerFunc { return next })
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := app.Handle(ctx, &CreateUserCommand{Name: "John"}); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(app.snapshot().handlers) != 3 {
+		t.Errorf("expected 3 handlers, got %d", len(app.snapshot().handlers))
+	}
+}
+
 // Benchmark tests
 func BenchmarkApp_Handle(b *testing.B) {
 	userModule := NewUserModule()
@@ -0,0 +1,272 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull is returned when an AsyncDispatcher cannot accept an event without blocking
+	ErrQueueFull = errors.New("dispatcher queue is full")
+	// ErrDispatcherClosed is returned when dispatching to an AsyncDispatcher after Shutdown
+	ErrDispatcherClosed = errors.New("dispatcher is closed")
+)
+
+// BackpressurePolicy decides what an AsyncDispatcher does when a queue is full
+type BackpressurePolicy int
+
+const (
+	// BlockWhenFull waits for queue space or for the dispatch context to be
+	// done. Handlers dispatching through the same AsyncDispatcher never wait:
+	// when the queue is full their events go to an overflow buffer of their
+	// worker, which grows as needed and is emptied as the queue drains.
+	BlockWhenFull BackpressurePolicy = iota
+	// RejectWhenFull returns ErrQueueFull immediately
+	RejectWhenFull
+)
+
+// AsyncDispatcherConfig configures an AsyncDispatcher
+type AsyncDispatcherConfig struct {
+	// Workers is the number of concurrent workers; defaults to runtime.NumCPU()
+	Workers int
+	// QueueSize is the buffer of each worker's queue; defaults to 64
+	QueueSize int
+	// Backpressure decides what happens when a worker's queue is full
+	Backpressure BackpressurePolicy
+	// ErrorHook receives failures returned by the wrapped dispatcher
+	ErrorHook ErrorHook
+}
+
+// AsyncDispatcher dispatches events to a wrapped Dispatcher on a bounded
+// worker pool. Events with the same AggregateID always go to the same worker,
+// so they are handled in the order they were dispatched.
+//
+// Handler failures cannot be returned to the caller; they are passed to the
+// ErrorHook, or logged when none is set.
+type AsyncDispatcher struct {
+	inner        Dispatcher
+	workers      []*asyncWorker
+	backpressure BackpressurePolicy
+	errorHook    ErrorHook
+
+	mu      sync.RWMutex // guards closing against new external dispatches
+	closing bool
+	pending sync.WaitGroup // events queued, in overflow or being handled
+	running sync.WaitGroup
+	next    atomic.Uint32 // round robin for events without an aggregate
+}
+
+type asyncEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// asyncWorker handles the events of one queue
+type asyncWorker struct {
+	dispatcher *AsyncDispatcher
+	queue      chan asyncEvent
+
+	mu sync.Mutex
+	// overflow holds events its handlers dispatched to full queues, oldest first
+	overflow []overflowEvent
+}
+
+type overflowEvent struct {
+	queue chan asyncEvent
+	item  asyncEvent
+}
+
+// asyncDeliveryKey marks contexts passed to handlers by an AsyncDispatcher
+// worker with the *asyncDelivery being handled
+type asyncDeliveryKey struct{}
+
+// asyncDelivery is an event in flight on a worker
+type asyncDelivery struct {
+	worker   *asyncWorker
+	finished bool // guarded by worker.mu
+}
+
+func NewAsyncDispatcher(inner Dispatcher, config AsyncDispatcherConfig) *AsyncDispatcher {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 64
+	}
+
+	d := &AsyncDispatcher{
+		inner:        inner,
+		workers:      make([]*asyncWorker, config.Workers),
+		backpressure: config.Backpressure,
+		errorHook:    config.ErrorHook,
+	}
+	for i := range d.workers {
+		d.workers[i] = &asyncWorker{dispatcher: d, queue: make(chan asyncEvent, config.QueueSize)}
+		d.running.Add(1)
+		go d.workers[i].work()
+	}
+	return d
+}
+
+func (d *AsyncDispatcher) Dispatch(event Event) error {
+	return d.DispatchCtx(context.Background(), event)
+}
+
+// DispatchCtx queues the event and returns once it is accepted. The handlers
+// receive ctx without its cancellation, so they still run after the caller returns.
+//
+// Handlers dispatching with the ctx they were given, while their event is
+// being handled, take part in the in-flight work: their events are accepted
+// during Shutdown, and they never block on a full queue, since the worker
+// they hold up may be the one to drain it.
+func (d *AsyncDispatcher) DispatchCtx(ctx context.Context, event Event) error {
+	item := asyncEvent{ctx: context.WithoutCancel(ctx), event: event}
+	queue := d.workers[d.shard(event)].queue
+
+	if delivery, ok := ctx.Value(asyncDeliveryKey{}).(*asyncDelivery); ok && delivery.worker.dispatcher == d {
+		if accepted, err := delivery.dispatch(queue, item); accepted {
+			return err
+		}
+	}
+
+	d.mu.RLock()
+	if d.closing {
+		d.mu.RUnlock()
+		return ErrDispatcherClosed
+	}
+	d.pending.Add(1)
+	d.mu.RUnlock()
+
+	if d.backpressure == RejectWhenFull {
+		select {
+		case queue <- item:
+			return nil
+		default:
+			d.pending.Done()
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case queue <- item:
+		return nil
+	case <-ctx.Done():
+		d.pending.Done()
+		return ctx.Err()
+	}
+}
+
+// dispatch queues item for a handler of the delivery without blocking,
+// reporting false once the delivery has finished. Its pending count keeps
+// Shutdown waiting, so it is accepted even while closing.
+func (v *asyncDelivery) dispatch(queue chan asyncEvent, item asyncEvent) (bool, error) {
+	w := v.worker
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if v.finished {
+		return false, nil
+	}
+
+	d := w.dispatcher
+	d.pending.Add(1)
+	// Events wait behind the overflow so they keep their dispatch order
+	if len(w.overflow) == 0 {
+		select {
+		case queue <- item:
+			return true, nil
+		default:
+		}
+	}
+	if d.backpressure == RejectWhenFull {
+		d.pending.Done()
+		return true, ErrQueueFull
+	}
+	w.overflow = append(w.overflow, overflowEvent{queue: queue, item: item})
+	return true, nil
+}
+
+// Shutdown stops accepting new events, waits for queued and in-flight events
+// to be handled, and stops the workers. If ctx is done first, it returns
+// ctx.Err() and the remaining events keep draining in the background.
+func (d *AsyncDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	alreadyClosing := d.closing
+	d.closing = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		if !alreadyClosing {
+			for _, w := range d.workers {
+				close(w.queue)
+			}
+		}
+		d.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *AsyncDispatcher) shard(event Event) int {
+	id := event.AggregateID()
+	if id == "" {
+		return int(d.next.Add(1) % uint32(len(d.workers)))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(d.workers)))
+}
+
+// work handles the events of the worker's queue and moves its overflow to
+// the target queues as they free up, until the queue is closed
+func (w *asyncWorker) work() {
+	defer w.dispatcher.running.Done()
+	for {
+		// Handlers add to the overflow only while their event is in flight,
+		// so it does not grow while the worker waits here
+		var out chan asyncEvent
+		var next asyncEvent
+		w.mu.Lock()
+		if len(w.overflow) > 0 {
+			out, next = w.overflow[0].queue, w.overflow[0].item
+		}
+		w.mu.Unlock()
+
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				return
+			}
+			w.handle(item)
+		case out <- next:
+			w.mu.Lock()
+			w.overflow = w.overflow[1:]
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *asyncWorker) handle(item asyncEvent) {
+	d := w.dispatcher
+	delivery := &asyncDelivery{worker: w}
+	ctx := context.WithValue(item.ctx, asyncDeliveryKey{}, delivery)
+	if err := d.inner.DispatchCtx(ctx, item.event); err != nil {
+		reportError(d.errorHook, ctx, item.event, err)
+	}
+
+	w.mu.Lock()
+	delivery.finished = true
+	w.mu.Unlock()
+	d.pending.Done()
+}
@@ -0,0 +1,286 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncDispatcher(t *testing.T) {
+	t.Run("preserves order per aggregate", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		var mu sync.Mutex
+		seen := map[string][]string{}
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[e.AggregateID()] = append(seen[e.AggregateID()], e.(*UserCreatedEvent).Name)
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 4, QueueSize: 8})
+
+		for i := 0; i < 50; i++ {
+			for _, user := range []string{"user-1", "user-2", "user-3"} {
+				if err := d.Dispatch(NewUserCreatedEvent(user, fmt.Sprint(i))); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		}
+
+		if err := d.Shutdown(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for user, names := range seen {
+			if len(names) != 50 {
+				t.Fatalf("expected 50 events for %s, got %d", user, len(names))
+			}
+			for i, name := range names {
+				if name != fmt.Sprint(i) {
+					t.Fatalf("expected event %d for %s in order, got %s", i, user, name)
+				}
+			}
+		}
+	})
+
+	t.Run("limits concurrency to worker count", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		var running, peak atomic.Int64
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 2})
+
+		for i := 0; i < 20; i++ {
+			d.Dispatch(NewUserCreatedEvent(fmt.Sprint("user-", i), "John"))
+		}
+		d.Shutdown(context.Background())
+
+		if peak.Load() > 2 {
+			t.Errorf("expected at most 2 concurrent handlers, got %d", peak.Load())
+		}
+	})
+
+	t.Run("rejects when full under reject policy", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		release := make(chan struct{})
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			<-release
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 1, QueueSize: 1, Backpressure: RejectWhenFull})
+
+		var rejected bool
+		for i := 0; i < 3; i++ {
+			if err := d.Dispatch(NewUserCreatedEvent("user-1", "John")); errors.Is(err, ErrQueueFull) {
+				rejected = true
+			}
+		}
+		close(release)
+		d.Shutdown(context.Background())
+
+		if !rejected {
+			t.Error("expected ErrQueueFull once the queue filled up")
+		}
+	})
+
+	t.Run("blocking dispatch honors context", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		release := make(chan struct{})
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			<-release
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 1, QueueSize: 1})
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := d.DispatchCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+		close(release)
+		d.Shutdown(context.Background())
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("shutdown drains events dispatched by handlers", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		var d *AsyncDispatcher
+		var deleted atomic.Int64
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			time.Sleep(time.Millisecond)
+			return nil, d.DispatchCtx(ctx, NewUserDeletedEvent(e.AggregateID()))
+		})
+		inner.Subscribe(&UserDeletedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			deleted.Add(1)
+			return nil, nil
+		})
+		d = NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 2})
+
+		for i := 0; i < 10; i++ {
+			d.Dispatch(NewUserCreatedEvent(fmt.Sprint("user-", i), "John"))
+		}
+
+		if err := d.Shutdown(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if deleted.Load() != 10 {
+			t.Errorf("expected 10 chained events, got %d", deleted.Load())
+		}
+
+		if err := d.Dispatch(NewUserCreatedEvent("user-1", "John")); !errors.Is(err, ErrDispatcherClosed) {
+			t.Errorf("expected ErrDispatcherClosed, got %v", err)
+		}
+	})
+
+	t.Run("handlers dispatching across full queues do not deadlock", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		var d *AsyncDispatcher
+		var failed, deleted atomic.Int64
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		d = NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 2, QueueSize: 1})
+
+		// Find two aggregates handled by different workers
+		ids := []string{"user-0"}
+		for i := 1; len(ids) < 2; i++ {
+			id := fmt.Sprint("user-", i)
+			if d.shard(NewUserCreatedEvent(id, "")) != d.shard(NewUserCreatedEvent(ids[0], "")) {
+				ids = append(ids, id)
+			}
+		}
+		other := map[string]string{ids[0]: ids[1], ids[1]: ids[0]}
+
+		// Each root handler floods the other worker's queue once both run
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			started <- struct{}{}
+			<-release
+			for i := 0; i < 5; i++ {
+				if err := d.DispatchCtx(ctx, NewUserDeletedEvent(other[e.AggregateID()])); err != nil {
+					failed.Add(1)
+				}
+			}
+			return nil, nil
+		})
+		inner.Subscribe(&UserDeletedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			deleted.Add(1)
+			return nil, nil
+		})
+
+		d.Dispatch(NewUserCreatedEvent(ids[0], "John"))
+		d.Dispatch(NewUserCreatedEvent(ids[1], "Jane"))
+		<-started
+		<-started
+		close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := d.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if failed.Load() != 0 || deleted.Load() != 10 {
+			t.Errorf("expected 10 events delivered through the overflow, got %d with %d failures", deleted.Load(), failed.Load())
+		}
+	})
+
+	t.Run("handlers get ErrQueueFull under reject policy", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		var d *AsyncDispatcher
+		var rejected atomic.Int64
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			for i := 0; i < 3; i++ {
+				if err := d.DispatchCtx(ctx, NewUserDeletedEvent(e.AggregateID())); errors.Is(err, ErrQueueFull) {
+					rejected.Add(1)
+				}
+			}
+			return nil, nil
+		})
+		d = NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 1, QueueSize: 1, Backpressure: RejectWhenFull})
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Shutdown(context.Background())
+
+		if rejected.Load() != 2 {
+			t.Errorf("expected 2 rejected dispatches, got %d", rejected.Load())
+		}
+	})
+
+	t.Run("rejects handler contexts used after their event was handled", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		kept := make(chan context.Context, 1)
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			kept <- ctx
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 1})
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		if err := d.Shutdown(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := d.DispatchCtx(<-kept, NewUserDeletedEvent("user-1")); !errors.Is(err, ErrDispatcherClosed) {
+			t.Errorf("expected ErrDispatcherClosed, got %v", err)
+		}
+	})
+
+	t.Run("shutdown returns when context expires", func(t *testing.T) {
+		inner := NewInMemoryDispatcher()
+		release := make(chan struct{})
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			<-release
+			return nil, nil
+		})
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{Workers: 1})
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := d.Shutdown(ctx)
+		close(release)
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("reports handler failures to error hook", func(t *testing.T) {
+		errHandler := errors.New("handler failed")
+		inner := NewInMemoryDispatcher()
+		inner.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			return nil, errHandler
+		})
+		var hooked atomic.Value
+		d := NewAsyncDispatcher(inner, AsyncDispatcherConfig{
+			Workers: 1,
+			ErrorHook: func(ctx context.Context, event Event, err error) {
+				hooked.Store(err)
+			},
+		})
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Shutdown(context.Background())
+
+		err, _ := hooked.Load().(error)
+		if !errors.Is(err, errHandler) {
+			t.Errorf("expected hooked %v, got %v", errHandler, err)
+		}
+	})
+}
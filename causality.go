@@ -0,0 +1,88 @@
+package gocmdevt
+
+import "context"
+
+// Every command and event handled within one chain shares a correlation ID.
+// Each event also records its causation ID: the ID of the event or command
+// that directly caused it. Both travel through the context that App.Handle,
+// EventEmitter.EmitCtx and InMemoryDispatcher pass along.
+
+// Traceable is implemented by events that carry correlation and causation
+// IDs. Events embedding BaseEvent satisfy it when used by pointer.
+type Traceable interface {
+	causalIDs
+	SetCausality(correlationID, causationID string)
+}
+
+// causalIDs is the read-only half of Traceable, also met by BaseEvent values
+type causalIDs interface {
+	CorrelationID() string
+	CausationID() string
+}
+
+type causalityKey struct{}
+
+type causality struct {
+	correlationID string
+	causationID   string
+}
+
+// WithCorrelationID returns a ctx whose commands and events belong to the
+// chain identified by correlationID, such as an incoming request ID.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	c := causalityFrom(ctx)
+	c.correlationID = correlationID
+	return context.WithValue(ctx, causalityKey{}, c)
+}
+
+// CorrelationIDFromContext returns the correlation ID of the chain ctx belongs to
+func CorrelationIDFromContext(ctx context.Context) string {
+	return causalityFrom(ctx).correlationID
+}
+
+// CausationIDFromContext returns the ID of the command or event whose
+// handling ctx belongs to
+func CausationIDFromContext(ctx context.Context) string {
+	return causalityFrom(ctx).causationID
+}
+
+// ContextWithEvent returns the ctx to run handlers of event with, so the
+// commands and events they produce are caused by event. InMemoryDispatcher
+// calls it for every event; custom dispatchers should do the same.
+func ContextWithEvent(ctx context.Context, event Event) context.Context {
+	correlationID := CorrelationIDFromContext(ctx)
+	if t, ok := event.(Traceable); ok && t.CorrelationID() != "" {
+		correlationID = t.CorrelationID()
+	}
+	if correlationID == "" {
+		correlationID = event.EventID()
+	}
+	return withCause(ctx, correlationID, event.EventID())
+}
+
+func causalityFrom(ctx context.Context) causality {
+	c, _ := ctx.Value(causalityKey{}).(causality)
+	return c
+}
+
+// withCause returns ctx for work caused by causationID in the chain of correlationID
+func withCause(ctx context.Context, correlationID, causationID string) context.Context {
+	return context.WithValue(ctx, causalityKey{}, causality{correlationID, causationID})
+}
+
+// stampCausality fills in the correlation and causation IDs of event from
+// ctx unless it already has them. An event emitted outside any chain starts
+// its own, correlated by its ID.
+func stampCausality(ctx context.Context, event Event) {
+	t, ok := event.(Traceable)
+	if !ok || t.CorrelationID() != "" {
+		return
+	}
+
+	c := causalityFrom(ctx)
+	if c.correlationID == "" {
+		t.SetCausality(event.EventID(), "")
+		return
+	}
+	t.SetCausality(c.correlationID, c.causationID)
+}
@@ -0,0 +1,145 @@
+package gocmdevt
+
+import (
+	"context"
+	"testing"
+)
+
+type createUserCmd struct{ ID, Name string }
+
+type deleteUserCmd struct{ ID string }
+
+// newCausalityApp wires createUserCmd to emit UserCreatedEvent, which
+// triggers deleteUserCmd, which emits UserDeletedEvent
+func newCausalityApp(t *testing.T) (*App, *recordingLogWriter) {
+	t.Helper()
+	logWriter := &recordingLogWriter{}
+	dispatcher := NewInMemoryDispatcher()
+	emitter := NewEventEmitter(logWriter, dispatcher)
+	app := NewApp()
+
+	Register(app, func(ctx context.Context, cmd *createUserCmd) (any, error) {
+		return nil, emitter.EmitCtx(ctx, NewUserCreatedEvent(cmd.ID, cmd.Name))
+	})
+	Register(app, func(ctx context.Context, cmd *deleteUserCmd) (any, error) {
+		return nil, emitter.EmitCtx(ctx, NewUserDeletedEvent(cmd.ID))
+	})
+	dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+		return app.Handle(ctx, &deleteUserCmd{ID: e.AggregateID()})
+	})
+	return app, logWriter
+}
+
+func TestCausality(t *testing.T) {
+	t.Run("links events of a command chain", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+
+		if _, err := app.Handle(context.Background(), &createUserCmd{ID: "user-1", Name: "John"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(logWriter.events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(logWriter.events))
+		}
+		created := logWriter.events[0].(*UserCreatedEvent)
+		deleted := logWriter.events[1].(*UserDeletedEvent)
+
+		if created.CorrelationID() == "" {
+			t.Fatal("expected a correlation ID, got none")
+		}
+		if deleted.CorrelationID() != created.CorrelationID() {
+			t.Errorf("expected correlation %s, got %s", created.CorrelationID(), deleted.CorrelationID())
+		}
+
+		// The root command's ID is the chain's correlation ID
+		if created.CausationID() != created.CorrelationID() {
+			t.Errorf("expected causation %s, got %s", created.CorrelationID(), created.CausationID())
+		}
+		if deleted.CausationID() != created.EventID() {
+			t.Errorf("expected causation %s, got %s", created.EventID(), deleted.CausationID())
+		}
+	})
+
+	t.Run("starts a new chain per root command", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+
+		app.Handle(context.Background(), &createUserCmd{ID: "user-1"})
+		app.Handle(context.Background(), &createUserCmd{ID: "user-2"})
+
+		first := logWriter.events[0].(*UserCreatedEvent)
+		second := logWriter.events[2].(*UserCreatedEvent)
+		if first.CorrelationID() == second.CorrelationID() {
+			t.Errorf("expected distinct correlation IDs, got %s twice", first.CorrelationID())
+		}
+	})
+
+	t.Run("uses the correlation ID from the context", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+
+		ctx := WithCorrelationID(context.Background(), "request-1")
+		app.Handle(ctx, &createUserCmd{ID: "user-1"})
+
+		for _, event := range logWriter.events {
+			if got := event.(Traceable).CorrelationID(); got != "request-1" {
+				t.Errorf("expected correlation request-1, got %s", got)
+			}
+		}
+	})
+
+	t.Run("correlates events emitted outside a command by their own ID", func(t *testing.T) {
+		emitter := NewEventEmitter(&recordingLogWriter{}, NewInMemoryDispatcher())
+
+		event := NewUserCreatedEvent("user-1", "John")
+		emitter.Emit(event)
+
+		if event.CorrelationID() != event.EventID() || event.CausationID() != "" {
+			t.Errorf("expected correlation %s and no causation, got %s and %s", event.EventID(), event.CorrelationID(), event.CausationID())
+		}
+	})
+
+	t.Run("keeps explicitly set IDs", func(t *testing.T) {
+		emitter := NewEventEmitter(&recordingLogWriter{}, NewInMemoryDispatcher())
+
+		event := NewUserCreatedEvent("user-1", "John")
+		event.SetCausality("corr-1", "cause-1")
+		emitter.EmitCtx(WithCorrelationID(context.Background(), "other"), event)
+
+		if event.CorrelationID() != "corr-1" || event.CausationID() != "cause-1" {
+			t.Errorf("expected corr-1 and cause-1, got %s and %s", event.CorrelationID(), event.CausationID())
+		}
+	})
+
+	t.Run("exposes the event being handled as causation", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		var correlationID, causationID string
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			correlationID = CorrelationIDFromContext(ctx)
+			causationID = CausationIDFromContext(ctx)
+			return nil, nil
+		})
+
+		event := NewUserCreatedEvent("user-1", "John")
+		event.SetCausality("corr-1", "")
+		dispatcher.Dispatch(event)
+
+		if correlationID != "corr-1" || causationID != event.EventID() {
+			t.Errorf("expected corr-1 and %s, got %s and %s", event.EventID(), correlationID, causationID)
+		}
+	})
+
+	t.Run("round-trips through JSONCodec", func(t *testing.T) {
+		event := NewUserCreatedEvent("user-1", "John")
+		event.SetCausality("corr-1", "cause-1")
+
+		data, _ := JSONCodec{}.Marshal(event)
+		decoded, err := JSONCodec{}.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		traced := decoded.(Traceable)
+		if traced.CorrelationID() != "corr-1" || traced.CausationID() != "cause-1" {
+			t.Errorf("expected corr-1 and cause-1, got %s and %s", traced.CorrelationID(), traced.CausationID())
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package gocmdevt
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time for event timestamps, snapshots, retries and other
+// time-dependent components. The clocktest package provides a fake.
+type Clock interface {
+	Now() time.Time
+	// After waits for d to pass and then sends the current time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the Clock of the time package
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+var defaultClock atomic.Pointer[Clock]
+
+func init() {
+	SetDefaultClock(SystemClock{})
+}
+
+// SetDefaultClock sets the clock used when none is given. The default is
+// SystemClock.
+func SetDefaultClock(c Clock) {
+	defaultClock.Store(&c)
+}
+
+// DefaultClock returns the package's default Clock
+func DefaultClock() Clock {
+	return *defaultClock.Load()
+}
+
+// clockOrDefault returns c, or the default clock when c is nil
+func clockOrDefault(c Clock) Clock {
+	if c == nil {
+		return DefaultClock()
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package gocmdevt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leviplj/go-cmd-evt/clocktest"
+)
+
+var _ Clock = (*clocktest.Clock)(nil)
+
+func TestNewBaseEvent_Clock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("uses an injected clock", func(t *testing.T) {
+		event := NewBaseEvent("UserCreated", "user-1", 1, WithClock(clocktest.New(now)))
+
+		if !event.Time.Equal(now) {
+			t.Errorf("expected time %v, got %v", now, event.Time)
+		}
+	})
+
+	t.Run("uses the package default", func(t *testing.T) {
+		previous := DefaultClock()
+		defer SetDefaultClock(previous)
+		SetDefaultClock(clocktest.New(now))
+
+		if event := NewUserCreatedEvent("user-1", "John"); !event.EventTime().Equal(now) {
+			t.Errorf("expected time %v, got %v", now, event.EventTime())
+		}
+		if cmd := NewBaseCommand("alice", "acme"); !cmd.IssuedAt.Equal(now) {
+			t.Errorf("expected issue time %v, got %v", now, cmd.IssuedAt)
+		}
+	})
+
+	t.Run("stores UTC times", func(t *testing.T) {
+		local := now.In(time.FixedZone("UTC+2", 2*60*60))
+		event := NewBaseEvent("UserCreated", "user-1", 1, WithClock(clocktest.New(local)))
+
+		if event.Time.Location() != time.UTC {
+			t.Errorf("expected UTC, got %v", event.Time.Location())
+		}
+	})
+
+	t.Run("timestamps time-ordered IDs", func(t *testing.T) {
+		clock := clocktest.New(now)
+		uuid := (&UUIDv7Generator{Clock: clock}).NewID()
+		ulid := (&ULIDGenerator{Clock: clock}).NewID()
+
+		// 2024-03-01T12:00:00Z is 0x018df9e2b200 milliseconds after the epoch
+		if uuid[:13] != "018df9e2-b200" {
+			t.Errorf("expected UUID timestamp 018df9e2-b200, got %s", uuid[:13])
+		}
+		if ulid[:10] != "01HQWY5CG0" {
+			t.Errorf("expected ULID timestamp 01HQWY5CG0, got %s", ulid[:10])
+		}
+	})
+}
+
+func TestFakeClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("fires waits once advanced past them", func(t *testing.T) {
+		clock := clocktest.New(now)
+		short := clock.After(time.Second)
+		long := clock.After(time.Minute)
+
+		clock.Advance(30 * time.Second)
+
+		select {
+		case got := <-short:
+			if !got.Equal(now.Add(30 * time.Second)) {
+				t.Errorf("expected %v, got %v", now.Add(30*time.Second), got)
+			}
+		default:
+			t.Error("expected short wait to fire")
+		}
+		select {
+		case <-long:
+			t.Error("expected long wait to be pending")
+		default:
+		}
+
+		if clock.Waiters() != 1 {
+			t.Errorf("expected 1 waiter, got %d", clock.Waiters())
+		}
+	})
+
+	t.Run("fires non-positive waits immediately", func(t *testing.T) {
+		clock := clocktest.New(now)
+
+		select {
+		case <-clock.After(0):
+		default:
+			t.Error("expected immediate fire")
+		}
+	})
+
+	t.Run("blocks until waiters arrive", func(t *testing.T) {
+		clock := clocktest.New(now)
+		done := make(chan time.Time)
+		go func() {
+			done <- <-clock.After(time.Second)
+		}()
+
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+
+		if got := <-done; !got.Equal(now.Add(time.Second)) {
+			t.Errorf("expected %v, got %v", now.Add(time.Second), got)
+		}
+	})
+}
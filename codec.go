@@ -0,0 +1,86 @@
+package gocmdevt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventCodec turns events into bytes and back for stores, queues and replay tools.
+type EventCodec interface {
+	Marshal(event Event) ([]byte, error)
+	Unmarshal(data []byte) (Event, error)
+}
+
+// eventEnvelope is the JSON form written by JSONCodec
+type eventEnvelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Aggregate string    `json:"aggregate_id"`
+	Version   int       `json:"version"`
+	// Correlation and Causation are set for Traceable events
+	Correlation string          `json:"correlation_id,omitempty"`
+	Causation   string          `json:"causation_id,omitempty"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// JSONCodec encodes events as a JSON envelope of their metadata and their
+// JSON form. It does not know Go event types, so Unmarshal returns a
+// *RecordedEvent carrying the raw data.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(event Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event %s: %w", event.EventID(), err)
+	}
+	env := eventEnvelope{
+		ID:        event.EventID(),
+		Type:      event.EventType(),
+		Time:      event.EventTime(),
+		Aggregate: event.AggregateID(),
+		Version:   event.EventVersion(),
+		Data:      data,
+	}
+	if t, ok := event.(causalIDs); ok {
+		env.Correlation = t.CorrelationID()
+		env.Causation = t.CausationID()
+	}
+	return json.Marshal(env)
+}
+
+func (JSONCodec) Unmarshal(data []byte) (Event, error) {
+	var env eventEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("unmarshal event envelope: %w", err)
+	}
+	recorded := &RecordedEvent{Data: env.Data}
+	// Events embedding BaseEvent keep further metadata such as the tenant in
+	// their data; the envelope has the final say on what it carries
+	json.Unmarshal(env.Data, &recorded.BaseEvent)
+	recorded.BaseEvent.ID = env.ID
+	recorded.BaseEvent.Type = env.Type
+	recorded.BaseEvent.Time = env.Time
+	recorded.BaseEvent.Aggregate = env.Aggregate
+	recorded.BaseEvent.Version = env.Version
+	recorded.BaseEvent.Correlation = env.Correlation
+	recorded.BaseEvent.Causation = env.Causation
+	return recorded, nil
+}
+
+// RecordedEvent is an event decoded without knowing its Go type
+type RecordedEvent struct {
+	BaseEvent
+	// Data is the JSON form of the original event
+	Data json.RawMessage `json:"data"`
+}
+
+// Payload returns the decoded fields of Data
+func (e *RecordedEvent) Payload() map[string]interface{} {
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(e.Data, &payload); err != nil {
+		return e.BaseEvent.Payload()
+	}
+	return payload
+}
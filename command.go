@@ -0,0 +1,119 @@
+package gocmdevt
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// CommandMetadata describes a command: its ID, when and by whom it was
+// issued, the tenant it acts for and any further headers.
+type CommandMetadata struct {
+	ID        string            `json:"id"`
+	IssuedAt  time.Time         `json:"issued_at"`
+	Principal string            `json:"principal,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// MetadataCommand is implemented by commands that carry metadata, such as
+// those embedding BaseCommand.
+type MetadataCommand interface {
+	Metadata() CommandMetadata
+}
+
+// BaseCommand can be embedded in commands to give them metadata
+type BaseCommand struct {
+	CommandMetadata
+}
+
+func NewBaseCommand(principal, tenant string) BaseCommand {
+	return BaseCommand{CommandMetadata{
+		ID:        generateEventID(),
+		IssuedAt:  DefaultClock().Now().UTC(),
+		Principal: principal,
+		Tenant:    tenant,
+	}}
+}
+
+func (c BaseCommand) Metadata() CommandMetadata {
+	return c.CommandMetadata
+}
+
+// CommandEnvelope attaches metadata to a command that has none of its own.
+// App.Handle runs the handler registered for the wrapped command.
+type CommandEnvelope struct {
+	Metadata CommandMetadata
+	Command  Command
+}
+
+type commandMetadataKey struct{}
+
+// CommandMetadataFromContext returns the metadata of the command being
+// handled. Commands without metadata inherit that of the command whose
+// handling triggered them.
+func CommandMetadataFromContext(ctx context.Context) (CommandMetadata, bool) {
+	meta, ok := ctx.Value(commandMetadataKey{}).(CommandMetadata)
+	return meta, ok
+}
+
+// unwrapCommand returns the command to handle and its metadata, filling in
+// a missing ID and issue time
+func unwrapCommand(cmd Command) (Command, CommandMetadata, bool) {
+	var meta CommandMetadata
+	switch c := cmd.(type) {
+	case CommandEnvelope:
+		cmd, meta = c.Command, c.Metadata
+	case *CommandEnvelope:
+		cmd, meta = c.Command, c.Metadata
+	case MetadataCommand:
+		meta = c.Metadata()
+	default:
+		return cmd, meta, false
+	}
+
+	if meta.ID == "" {
+		meta.ID = generateEventID()
+	}
+	if meta.IssuedAt.IsZero() {
+		meta.IssuedAt = DefaultClock().Now().UTC()
+	}
+	return cmd, meta, true
+}
+
+// withCommandMetadata returns ctx for handling a command with meta. The
+// command's ID becomes the causation ID of the events it emits.
+func withCommandMetadata(ctx context.Context, meta CommandMetadata) context.Context {
+	correlationID := CorrelationIDFromContext(ctx)
+	if correlationID == "" {
+		correlationID = meta.ID
+	}
+	ctx = withCause(ctx, correlationID, meta.ID)
+	return context.WithValue(ctx, commandMetadataKey{}, meta)
+}
+
+// commandStamper is implemented by events that record command metadata
+type commandStamper interface {
+	StampCommand(meta CommandMetadata)
+}
+
+// stampCommand copies the metadata of the command in ctx into event
+func stampCommand(ctx context.Context, event Event) {
+	stamper, ok := event.(commandStamper)
+	if !ok {
+		return
+	}
+	if meta, ok := CommandMetadataFromContext(ctx); ok {
+		stamper.StampCommand(meta)
+	}
+}
+
+// mergeHeaders returns headers with the entries of extra it lacks
+func mergeHeaders(headers, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return headers
+	}
+	merged := maps.Clone(extra)
+	maps.Copy(merged, headers)
+	return merged
+}
@@ -0,0 +1,146 @@
+package gocmdevt
+
+import (
+	"context"
+	"testing"
+)
+
+type renameUserCmd struct {
+	BaseCommand
+	UserID string
+	Name   string
+}
+
+func TestApp_CommandMetadata(t *testing.T) {
+	t.Run("exposes metadata of BaseCommand commands to handlers", func(t *testing.T) {
+		app := NewApp()
+		var got CommandMetadata
+		Register(app, func(ctx context.Context, cmd *renameUserCmd) (any, error) {
+			got, _ = CommandMetadataFromContext(ctx)
+			return nil, nil
+		})
+
+		cmd := &renameUserCmd{BaseCommand: NewBaseCommand("alice", "acme"), UserID: "user-1"}
+		if _, err := app.Handle(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.ID != cmd.ID || got.Principal != "alice" || got.Tenant != "acme" {
+			t.Errorf("expected %+v, got %+v", cmd.CommandMetadata, got)
+		}
+	})
+
+	t.Run("unwraps envelopes for their command's handler", func(t *testing.T) {
+		app := NewApp()
+		var handled *createUserCmd
+		var got CommandMetadata
+		Register(app, func(ctx context.Context, cmd *createUserCmd) (any, error) {
+			handled = cmd
+			got, _ = CommandMetadataFromContext(ctx)
+			return nil, nil
+		})
+
+		cmd := &createUserCmd{ID: "user-1"}
+		envelope := CommandEnvelope{
+			Metadata: CommandMetadata{Principal: "bob", Headers: map[string]string{"source": "api"}},
+			Command:  cmd,
+		}
+		if _, err := app.Handle(context.Background(), envelope); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if handled != cmd {
+			t.Fatalf("expected wrapped command to be handled, got %v", handled)
+		}
+		if got.Principal != "bob" || got.Headers["source"] != "api" {
+			t.Errorf("expected metadata of the envelope, got %+v", got)
+		}
+		if got.ID == "" || got.IssuedAt.IsZero() {
+			t.Errorf("expected generated ID and issue time, got %+v", got)
+		}
+	})
+
+	t.Run("has no metadata for plain commands", func(t *testing.T) {
+		app := NewApp()
+		found := true
+		Register(app, func(ctx context.Context, cmd *createUserCmd) (any, error) {
+			_, found = CommandMetadataFromContext(ctx)
+			return nil, nil
+		})
+
+		app.Handle(context.Background(), &createUserCmd{})
+
+		if found {
+			t.Error("expected no metadata, got some")
+		}
+	})
+
+	t.Run("copies metadata into emitted events", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+
+		meta := CommandMetadata{
+			ID:        "cmd-1",
+			Principal: "alice",
+			Tenant:    "acme",
+			Headers:   map[string]string{"source": "api"},
+		}
+		ctx := WithCorrelationID(context.Background(), "request-1")
+		if _, err := app.Handle(ctx, &CommandEnvelope{Metadata: meta, Command: &createUserCmd{ID: "user-1"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		created := logWriter.events[0].(*UserCreatedEvent)
+		if created.CausationID() != "cmd-1" || created.CorrelationID() != "request-1" {
+			t.Errorf("expected causation cmd-1 and correlation request-1, got %s and %s", created.CausationID(), created.CorrelationID())
+		}
+
+		// The follow-up command has no metadata of its own and inherits it
+		for _, event := range logWriter.events {
+			base := event.Payload()
+			if base["principal"] != "alice" || base["tenant"] != "acme" {
+				t.Errorf("expected alice at acme, got %v at %v", base["principal"], base["tenant"])
+			}
+		}
+		if created.Headers["source"] != "api" {
+			t.Errorf("expected header source=api, got %v", created.Headers)
+		}
+	})
+
+	t.Run("correlates root commands by their ID", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+
+		app.Handle(context.Background(), CommandEnvelope{Metadata: CommandMetadata{ID: "cmd-1"}, Command: &createUserCmd{}})
+
+		created := logWriter.events[0].(*UserCreatedEvent)
+		if created.CorrelationID() != "cmd-1" {
+			t.Errorf("expected correlation cmd-1, got %s", created.CorrelationID())
+		}
+	})
+
+	t.Run("keeps event headers over command headers", func(t *testing.T) {
+		event := NewUserCreatedEvent("user-1", "John")
+		event.Headers = map[string]string{"source": "event"}
+
+		event.StampCommand(CommandMetadata{Tenant: "acme", Headers: map[string]string{"source": "api", "trace": "t-1"}})
+
+		if event.Headers["source"] != "event" || event.Headers["trace"] != "t-1" || event.Tenant != "acme" {
+			t.Errorf("expected merged metadata, got %+v", event.BaseEvent)
+		}
+	})
+
+	t.Run("round-trips metadata through JSONCodec", func(t *testing.T) {
+		event := NewUserCreatedEvent("user-1", "John")
+		event.StampCommand(CommandMetadata{Principal: "alice", Tenant: "acme"})
+
+		data, _ := JSONCodec{}.Marshal(event)
+		decoded, err := JSONCodec{}.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		recorded := decoded.(*RecordedEvent)
+		if recorded.Principal != "alice" || recorded.Tenant != "acme" {
+			t.Errorf("expected alice at acme, got %+v", recorded.BaseEvent)
+		}
+	})
+}
@@ -0,0 +1,279 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Delivery is a message read from a MessageSource
+type Delivery struct {
+	Message
+	// Offset is the position of the message in its topic, from zero
+	Offset int64
+}
+
+// MessageSource is the receiving side of a broker adapter. Sources are read
+// by offset, so a Consumer can resume from its checkpoints.
+type MessageSource interface {
+	// Fetch returns up to limit messages of topic from offset on, oldest
+	// first. It waits until there is at least one or ctx is done.
+	Fetch(ctx context.Context, topic string, offset int64, limit int) ([]Delivery, error)
+}
+
+// Acknowledger is implemented by sources that track deliveries themselves,
+// such as AMQP queues. The Consumer acknowledges each message after it was
+// handled and rejects it when handling failed.
+type Acknowledger interface {
+	Ack(ctx context.Context, d Delivery) error
+	Nack(ctx context.Context, d Delivery) error
+}
+
+// CheckpointStore keeps the offset each consumer has reached per topic
+type CheckpointStore interface {
+	// LoadCheckpoint returns the offset to resume topic from, zero if none
+	LoadCheckpoint(ctx context.Context, consumer, topic string) (int64, error)
+	SaveCheckpoint(ctx context.Context, consumer, topic string, offset int64) error
+}
+
+// InMemoryCheckpointStore is a CheckpointStore for tests and single-process
+// setups.
+type InMemoryCheckpointStore struct {
+	mu      sync.Mutex
+	offsets map[[2]string]int64
+}
+
+func NewInMemoryCheckpointStore() *InMemoryCheckpointStore {
+	return &InMemoryCheckpointStore{offsets: map[[2]string]int64{}}
+}
+
+func (s *InMemoryCheckpointStore) LoadCheckpoint(ctx context.Context, consumer, topic string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offsets[[2]string{consumer, topic}], nil
+}
+
+func (s *InMemoryCheckpointStore) SaveCheckpoint(ctx context.Context, consumer, topic string, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets[[2]string{consumer, topic}] = offset
+	return nil
+}
+
+// ###
+
+// ErrConsumerStopped is returned when starting a Consumer after Shutdown
+var ErrConsumerStopped = errors.New("consumer is stopped")
+
+// ConsumerConfig configures a Consumer
+type ConsumerConfig struct {
+	// Name identifies the consumer's checkpoints; required
+	Name string
+	// Topics to consume; required
+	Topics []string
+	// Codec decodes message values; defaults to JSONCodec. Use an
+	// EventRegistry to get typed events.
+	Codec EventCodec
+	// Checkpoints keeps the consumer's offsets; defaults to a new
+	// InMemoryCheckpointStore
+	Checkpoints CheckpointStore
+	// BatchSize is the number of messages fetched at once; defaults to 100
+	BatchSize int
+	// RetryDelay is the pause before a failed message is redelivered, and
+	// after errors of the source; defaults to one second
+	RetryDelay time.Duration
+	// MaxAttempts skips messages after that many failed deliveries; zero
+	// retries forever
+	MaxAttempts int
+	// ErrorHook receives delivery failures and source errors
+	ErrorHook ErrorHook
+	// Clock paces the retries; defaults to DefaultClock
+	Clock Clock
+}
+
+// Consumer reads events published by another service from a MessageSource
+// and dispatches them, at least once and in order per topic. A message is
+// acknowledged and checkpointed only after the dispatcher accepted it; when
+// dispatching fails it is redelivered and the topic waits for it. Messages
+// that cannot be decoded are reported and skipped.
+type Consumer struct {
+	source     MessageSource
+	dispatcher Dispatcher
+	config     ConsumerConfig
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewConsumer(source MessageSource, dispatcher Dispatcher, config ConsumerConfig) *Consumer {
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+	if config.Checkpoints == nil {
+		config.Checkpoints = NewInMemoryCheckpointStore()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Second
+	}
+	config.Clock = clockOrDefault(config.Clock)
+
+	return &Consumer{
+		source:     source,
+		dispatcher: dispatcher,
+		config:     config,
+	}
+}
+
+// Start consumes each topic in a goroutine until Shutdown
+func (c *Consumer) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		return ErrConsumerStopped
+	}
+	if c.started {
+		return nil
+	}
+	if c.config.Name == "" || len(c.config.Topics) == 0 {
+		return errors.New("consumer needs a name and topics")
+	}
+	c.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	for _, topic := range c.config.Topics {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.consume(ctx, topic)
+		}()
+	}
+	return nil
+}
+
+func (c *Consumer) consume(ctx context.Context, topic string) {
+	var offset int64
+	for {
+		var err error
+		if offset, err = c.config.Checkpoints.LoadCheckpoint(ctx, c.config.Name, topic); err == nil {
+			break
+		}
+		reportError(c.config.ErrorHook, ctx, nil, fmt.Errorf("load checkpoint of topic %s: %w", topic, err))
+		if !c.wait(ctx) {
+			return
+		}
+	}
+
+	attempts := 0 // failed deliveries of the message at offset
+	for {
+		deliveries, err := c.source.Fetch(ctx, topic, offset, c.config.BatchSize)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			reportError(c.config.ErrorHook, ctx, nil, fmt.Errorf("fetch topic %s: %w", topic, err))
+			if !c.wait(ctx) {
+				return
+			}
+			continue
+		}
+
+		for _, d := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+			if !c.deliver(ctx, topic, d, attempts) {
+				attempts++
+				break
+			}
+			offset, attempts = d.Offset+1, 0
+		}
+		if attempts > 0 && !c.wait(ctx) {
+			return
+		}
+	}
+}
+
+// deliver decodes and dispatches d, reporting whether it is done with
+func (c *Consumer) deliver(ctx context.Context, topic string, d Delivery, attempts int) bool {
+	// Handling is not interrupted by Shutdown, so it ends with an ack or nack
+	ctx = context.WithoutCancel(ctx)
+
+	event, err := c.config.Codec.Unmarshal(d.Value)
+	if err != nil {
+		reportError(c.config.ErrorHook, ctx, nil, fmt.Errorf("decode message %d of topic %s: %w", d.Offset, topic, err))
+		c.ack(ctx, topic, d)
+		return true
+	}
+
+	if err := c.dispatcher.DispatchCtx(ctx, event); err != nil {
+		if c.config.MaxAttempts > 0 && attempts+1 >= c.config.MaxAttempts {
+			reportError(c.config.ErrorHook, ctx, event, fmt.Errorf("message %d of topic %s skipped after %d attempts: %w", d.Offset, topic, attempts+1, err))
+			c.ack(ctx, topic, d)
+			return true
+		}
+		reportError(c.config.ErrorHook, ctx, event, err)
+		if a, ok := c.source.(Acknowledger); ok {
+			if err := a.Nack(ctx, d); err != nil {
+				reportError(c.config.ErrorHook, ctx, event, fmt.Errorf("nack message %d of topic %s: %w", d.Offset, topic, err))
+			}
+		}
+		return false
+	}
+	c.ack(ctx, topic, d)
+	return true
+}
+
+// ack acknowledges d and moves the topic's checkpoint past it
+func (c *Consumer) ack(ctx context.Context, topic string, d Delivery) {
+	if a, ok := c.source.(Acknowledger); ok {
+		if err := a.Ack(ctx, d); err != nil {
+			reportError(c.config.ErrorHook, ctx, nil, fmt.Errorf("ack message %d of topic %s: %w", d.Offset, topic, err))
+		}
+	}
+	if err := c.config.Checkpoints.SaveCheckpoint(ctx, c.config.Name, topic, d.Offset+1); err != nil {
+		reportError(c.config.ErrorHook, ctx, nil, fmt.Errorf("save checkpoint of topic %s: %w", topic, err))
+	}
+}
+
+// wait pauses for RetryDelay, reporting false if ctx is done first
+func (c *Consumer) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.config.Clock.After(c.config.RetryDelay):
+		return true
+	}
+}
+
+// Shutdown stops fetching and waits until the messages being handled are
+// done or ctx is.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopped = true
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
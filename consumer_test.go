@@ -0,0 +1,269 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leviplj/go-cmd-evt/clocktest"
+)
+
+// ackingSource records the acknowledgements of a LoopbackBroker's messages
+type ackingSource struct {
+	*LoopbackBroker
+	mu    sync.Mutex
+	acks  []int64
+	nacks []int64
+}
+
+func (s *ackingSource) Ack(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acks = append(s.acks, d.Offset)
+	return nil
+}
+
+func (s *ackingSource) Nack(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nacks = append(s.nacks, d.Offset)
+	return nil
+}
+
+// startConsumer starts a consumer of the UserCreated topic decoding with a
+// registry, and shuts it down when the test ends
+func startConsumer(t *testing.T, source MessageSource, dispatcher Dispatcher, config ConsumerConfig) *Consumer {
+	t.Helper()
+	registry := NewEventRegistry()
+	RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+	config.Name = "billing"
+	config.Topics = []string{"UserCreated"}
+	config.Codec = registry
+
+	consumer := NewConsumer(source, dispatcher, config)
+	if err := consumer.Start(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := consumer.Shutdown(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	return consumer
+}
+
+// publishUsers publishes a UserCreatedEvent per name
+func publishUsers(t *testing.T, broker *LoopbackBroker, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		msg, err := MessageMapper{}.Message(NewUserCreatedEvent("user-"+name, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		broker.Publish(context.Background(), msg)
+	}
+}
+
+// receiveNames subscribes to UserCreatedEvent, failing the first failures
+// deliveries
+func receiveNames(dispatcher *InMemoryDispatcher, failures int) <-chan string {
+	names := make(chan string, 10)
+	var mu sync.Mutex
+	dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return nil, errors.New("handler down")
+		}
+		names <- e.(*UserCreatedEvent).Name
+		return nil, nil
+	})
+	return names
+}
+
+func TestConsumer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dispatches published events as typed events", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		dispatcher := NewInMemoryDispatcher()
+		received := make(chan *UserCreatedEvent, 1)
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			received <- e.(*UserCreatedEvent)
+			return nil, nil
+		})
+		startConsumer(t, broker, dispatcher, ConsumerConfig{})
+
+		emitter := NewEventEmitter(&recordingLogWriter{}, NewInMemoryDispatcher())
+		emitter.Publisher = broker
+		event := NewUserCreatedEvent("user-1", "John")
+		emitter.EmitCtx(WithCorrelationID(ctx, "request-1"), event)
+
+		got := <-received
+		if got.Name != "John" || got.EventID() != event.EventID() {
+			t.Errorf("expected event %s of John, got %s of %s", event.EventID(), got.EventID(), got.Name)
+		}
+		if got.CorrelationID() != "request-1" {
+			t.Errorf("expected correlation request-1, got %s", got.CorrelationID())
+		}
+	})
+
+	t.Run("resumes from its checkpoint", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		publishUsers(t, broker, "John", "Jane")
+		checkpoints := NewInMemoryCheckpointStore()
+		checkpoints.SaveCheckpoint(ctx, "billing", "UserCreated", 1)
+		dispatcher := NewInMemoryDispatcher()
+		names := receiveNames(dispatcher, 0)
+
+		startConsumer(t, broker, dispatcher, ConsumerConfig{Checkpoints: checkpoints})
+
+		if got := <-names; got != "Jane" {
+			t.Errorf("expected Jane, got %s", got)
+		}
+		publishUsers(t, broker, "Bob")
+		<-names
+		if offset, _ := checkpoints.LoadCheckpoint(ctx, "billing", "UserCreated"); offset != 3 {
+			t.Errorf("expected checkpoint 3, got %d", offset)
+		}
+	})
+
+	t.Run("redelivers failed messages in order", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		publishUsers(t, broker, "John", "Jane")
+		dispatcher := NewInMemoryDispatcher()
+		names := receiveNames(dispatcher, 1)
+		hooked := make(chan error, 10)
+		clock := clocktest.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+		startConsumer(t, broker, dispatcher, ConsumerConfig{
+			RetryDelay: time.Second,
+			Clock:      clock,
+			ErrorHook:  func(ctx context.Context, event Event, err error) { hooked <- err },
+		})
+
+		<-hooked
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		if first, second := <-names, <-names; first != "John" || second != "Jane" {
+			t.Errorf("expected John then Jane, got %s then %s", first, second)
+		}
+	})
+
+	t.Run("skips messages after MaxAttempts", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		publishUsers(t, broker, "John", "Jane")
+		dispatcher := NewInMemoryDispatcher()
+		names := receiveNames(dispatcher, 2)
+		clock := clocktest.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+		startConsumer(t, broker, dispatcher, ConsumerConfig{MaxAttempts: 2, Clock: clock})
+
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		if got := <-names; got != "Jane" {
+			t.Errorf("expected Jane, got %s", got)
+		}
+	})
+
+	t.Run("skips messages it cannot decode", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		broker.Publish(ctx, Message{Topic: "UserCreated", Value: []byte("not json")})
+		publishUsers(t, broker, "John")
+		dispatcher := NewInMemoryDispatcher()
+		names := receiveNames(dispatcher, 0)
+		hooked := make(chan error, 10)
+
+		startConsumer(t, broker, dispatcher, ConsumerConfig{
+			ErrorHook: func(ctx context.Context, event Event, err error) { hooked <- err },
+		})
+
+		if got := <-names; got != "John" {
+			t.Errorf("expected John, got %s", got)
+		}
+		if err := <-hooked; err == nil {
+			t.Error("expected decode error, got nil")
+		}
+	})
+
+	t.Run("acks and nacks through an Acknowledger", func(t *testing.T) {
+		source := &ackingSource{LoopbackBroker: NewLoopbackBroker()}
+		publishUsers(t, source.LoopbackBroker, "John")
+		dispatcher := NewInMemoryDispatcher()
+		names := receiveNames(dispatcher, 1)
+		clock := clocktest.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+		consumer := startConsumer(t, source, dispatcher, ConsumerConfig{Clock: clock})
+
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		<-names
+		consumer.Shutdown(ctx)
+
+		source.mu.Lock()
+		defer source.mu.Unlock()
+		if len(source.nacks) != 1 || len(source.acks) != 1 || source.acks[0] != 0 {
+			t.Errorf("expected one nack and an ack of offset 0, got %v and %v", source.nacks, source.acks)
+		}
+	})
+
+	t.Run("does not restart after Shutdown", func(t *testing.T) {
+		consumer := startConsumer(t, NewLoopbackBroker(), NewInMemoryDispatcher(), ConsumerConfig{})
+
+		if err := consumer.Shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := consumer.Start(); !errors.Is(err, ErrConsumerStopped) {
+			t.Errorf("expected ErrConsumerStopped, got %v", err)
+		}
+	})
+
+	t.Run("requires a name and topics", func(t *testing.T) {
+		consumer := NewConsumer(NewLoopbackBroker(), NewInMemoryDispatcher(), ConsumerConfig{})
+		if err := consumer.Start(); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestLoopbackBroker_Fetch(t *testing.T) {
+	ctx := context.Background()
+	broker := NewLoopbackBroker()
+	broker.Publish(ctx, Message{Topic: "orders", Key: "a"}, Message{Topic: "orders", Key: "b"}, Message{Topic: "orders", Key: "c"})
+
+	t.Run("returns messages from the offset", func(t *testing.T) {
+		deliveries, err := broker.Fetch(ctx, "orders", 1, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Key != "b" || deliveries[0].Offset != 1 {
+			t.Errorf("expected message b at offset 1, got %+v", deliveries)
+		}
+	})
+
+	t.Run("waits for new messages", func(t *testing.T) {
+		fetched := make(chan []Delivery)
+		go func() {
+			deliveries, _ := broker.Fetch(ctx, "orders", 3, 0)
+			fetched <- deliveries
+		}()
+
+		broker.Publish(ctx, Message{Topic: "orders", Key: "d"})
+		if deliveries := <-fetched; len(deliveries) != 1 || deliveries[0].Key != "d" {
+			t.Errorf("expected message d, got %+v", deliveries)
+		}
+	})
+
+	t.Run("stops waiting when ctx is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := broker.Fetch(ctx, "orders", 10, 0); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package gocmdevt
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// InMemoryDispatcher dispatches events to handlers subscribed by Go type,
+// EventType name, glob pattern or predicate. Handlers run in subscription order.
+// It is safe for concurrent use: subscribing and unsubscribing copy the
+// subscription table and swap it in atomically, so Dispatch never takes a lock.
+type InMemoryDispatcher struct {
+	mu    sync.Mutex // serializes writers
+	seq   uint64     // guarded by mu
+	table atomic.Pointer[subscriptionTable]
+
+	// ErrorPolicy decides how handler failures are reported; set it before dispatching
+	ErrorPolicy ErrorPolicy
+	// ErrorHook receives handler failures under the RouteToHook policy
+	ErrorHook ErrorHook
+}
+
+// subscriptionTable is an immutable snapshot of a dispatcher's subscriptions
+type subscriptionTable struct {
+	// byType holds subscriptions keyed by Go type
+	byType map[reflect.Type][]*Subscription
+	// matchers holds name, pattern and predicate subscriptions
+	matchers []*Subscription
+}
+
+// EventMatcher reports whether a subscription wants an event
+type EventMatcher func(event Event) bool
+
+// MatchType matches events whose EventType is eventType.
+func MatchType(eventType string) EventMatcher {
+	return func(event Event) bool {
+		return event.EventType() == eventType
+	}
+}
+
+// MatchPattern matches events whose EventType matches the glob pattern, using
+// path.Match syntax: "Order.*" matches "Order.Created", "Order*" matches "OrderShipped".
+func MatchPattern(pattern string) (EventMatcher, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid event pattern %q: %w", pattern, err)
+	}
+	return func(event Event) bool {
+		ok, _ := path.Match(pattern, event.EventType())
+		return ok
+	}, nil
+}
+
+// MatchAggregate matches events emitted by the aggregate with the given ID.
+func MatchAggregate(aggregateID string) EventMatcher {
+	return func(event Event) bool {
+		return event.AggregateID() == aggregateID
+	}
+}
+
+// Subscription is a handle to a handler subscribed to an InMemoryDispatcher
+type Subscription struct {
+	dispatcher *InMemoryDispatcher
+	seq        uint64
+	eventType  reflect.Type
+	match      EventMatcher
+	handler    EventHandlerFunc
+	once       bool
+	fired      atomic.Bool
+	removed    atomic.Bool
+	retry      atomic.Pointer[RetryPolicy]
+	// stop detaches the context watcher of SubscribeCtx subscriptions. It is
+	// stored after the watcher may already be running Unsubscribe.
+	stop atomic.Pointer[func() bool]
+}
+
+// Unsubscribe removes the handler from the dispatcher. It is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	if !s.removed.CompareAndSwap(false, true) {
+		return
+	}
+	if stop := s.stop.Load(); stop != nil {
+		(*stop)()
+	}
+	s.dispatcher.remove(s)
+}
+
+// Active reports whether the subscription still receives events
+func (s *Subscription) Active() bool {
+	return !s.removed.Load()
+}
+
+// WithRetry makes the dispatcher retry failures of the handler under policy
+// before reporting them. The dispatch waits for the retries.
+func (s *Subscription) WithRetry(policy RetryPolicy) *Subscription {
+	policy = policy.withDefaults()
+	s.retry.Store(&policy)
+	return s
+}
+
+// call runs the handler, retrying it under the subscription's RetryPolicy,
+// and returns the number of calls made
+func (s *Subscription) call(ctx context.Context, event Event) (int, error) {
+	policy := s.retry.Load()
+	if policy == nil {
+		_, err := s.handler(ctx, event)
+		return 1, err
+	}
+	return policy.run(ctx, func() error {
+		_, err := s.handler(ctx, event)
+		return err
+	})
+}
+
+func NewInMemoryDispatcher() *InMemoryDispatcher {
+	d := &InMemoryDispatcher{}
+	d.table.Store(&subscriptionTable{byType: make(map[reflect.Type][]*Subscription)})
+	return d
+}
+
+// Subscribe adds handler for events of the same type as event. Pointer and
+// value forms of a type are treated alike, so &OrderCreatedEvent{} also
+// receives OrderCreatedEvent values.
+func (d *InMemoryDispatcher) Subscribe(event Event, handler EventHandlerFunc) *Subscription {
+	sub := d.newSubscription(event, handler)
+	d.add(sub)
+	return sub
+}
+
+// SubscribeOnce adds handler for the next event of the same type as event only.
+func (d *InMemoryDispatcher) SubscribeOnce(event Event, handler EventHandlerFunc) *Subscription {
+	sub := d.newSubscription(event, handler)
+	sub.once = true
+	d.add(sub)
+	return sub
+}
+
+// SubscribeCtx adds handler until ctx is cancelled, after which it is removed automatically.
+func (d *InMemoryDispatcher) SubscribeCtx(ctx context.Context, event Event, handler EventHandlerFunc) *Subscription {
+	sub := d.newSubscription(event, handler)
+	if ctx.Err() != nil {
+		sub.removed.Store(true)
+		return sub
+	}
+	d.add(sub)
+	stop := context.AfterFunc(ctx, sub.Unsubscribe)
+	sub.stop.Store(&stop)
+	return sub
+}
+
+// SubscribeType adds handler for events whose EventType is eventType.
+func (d *InMemoryDispatcher) SubscribeType(eventType string, handler EventHandlerFunc) *Subscription {
+	return d.SubscribeFunc(MatchType(eventType), handler)
+}
+
+// SubscribePattern adds handler for events whose EventType matches the glob
+// pattern (see MatchPattern).
+func (d *InMemoryDispatcher) SubscribePattern(pattern string, handler EventHandlerFunc) (*Subscription, error) {
+	match, err := MatchPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return d.SubscribeFunc(match, handler), nil
+}
+
+// SubscribeAll adds handler for every dispatched event.
+func (d *InMemoryDispatcher) SubscribeAll(handler EventHandlerFunc) *Subscription {
+	return d.SubscribeFunc(func(Event) bool { return true }, handler)
+}
+
+// SubscribeFunc adds handler for every event accepted by match.
+func (d *InMemoryDispatcher) SubscribeFunc(match EventMatcher, handler EventHandlerFunc) *Subscription {
+	sub := &Subscription{
+		dispatcher: d,
+		match:      match,
+		handler:    handler,
+	}
+	d.add(sub)
+	return sub
+}
+
+func (d *InMemoryDispatcher) newSubscription(event Event, handler EventHandlerFunc) *Subscription {
+	return &Subscription{
+		dispatcher: d,
+		eventType:  eventKey(event),
+		handler:    handler,
+	}
+}
+
+// eventKey returns the Go type used to route event, ignoring pointer indirection
+func eventKey(event Event) reflect.Type {
+	typ := reflect.TypeOf(event)
+	if typ != nil && typ.Kind() == reflect.Pointer {
+		return typ.Elem()
+	}
+	return typ
+}
+
+func (d *InMemoryDispatcher) add(sub *Subscription) {
+	d.update(func(t *subscriptionTable) {
+		d.seq++
+		sub.seq = d.seq
+		// Copy slices so in-flight dispatches keep their own view
+		if sub.match != nil {
+			t.matchers = append(t.matchers[:len(t.matchers):len(t.matchers)], sub)
+			return
+		}
+		existing := t.byType[sub.eventType]
+		t.byType[sub.eventType] = append(existing[:len(existing):len(existing)], sub)
+	})
+}
+
+func (d *InMemoryDispatcher) remove(sub *Subscription) {
+	d.update(func(t *subscriptionTable) {
+		if sub.match != nil {
+			t.matchers = without(t.matchers, sub)
+			return
+		}
+		kept := without(t.byType[sub.eventType], sub)
+		if len(kept) == 0 {
+			delete(t.byType, sub.eventType)
+			return
+		}
+		t.byType[sub.eventType] = kept
+	})
+}
+
+// without returns a copy of subs with sub removed
+func without(subs []*Subscription, sub *Subscription) []*Subscription {
+	kept := make([]*Subscription, 0, len(subs))
+	for _, s := range subs {
+		if s != sub {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// update applies fn to a copy of the subscription table and publishes it
+func (d *InMemoryDispatcher) update(fn func(t *subscriptionTable)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur := d.snapshot()
+	next := &subscriptionTable{
+		byType:   make(map[reflect.Type][]*Subscription, len(cur.byType)+1),
+		matchers: cur.matchers,
+	}
+	for typ, subs := range cur.byType {
+		next.byType[typ] = subs
+	}
+	fn(next)
+	d.table.Store(next)
+}
+
+// snapshot returns the current subscriptions; it must not be mutated
+func (d *InMemoryDispatcher) snapshot() *subscriptionTable {
+	if t := d.table.Load(); t != nil {
+		return t
+	}
+	return &subscriptionTable{}
+}
+
+// subscribers returns the subscriptions interested in event, in subscription order
+func (t *subscriptionTable) subscribers(event Event) []*Subscription {
+	typed := t.byType[eventKey(event)]
+	if len(t.matchers) == 0 {
+		return typed
+	}
+
+	subs := make([]*Subscription, 0, len(typed)+len(t.matchers))
+	i := 0
+	for _, m := range t.matchers {
+		if !m.match(event) {
+			continue
+		}
+		for i < len(typed) && typed[i].seq < m.seq {
+			subs = append(subs, typed[i])
+			i++
+		}
+		subs = append(subs, m)
+	}
+	return append(subs, typed[i:]...)
+}
+
+func (d *InMemoryDispatcher) Dispatch(event Event) error {
+	return d.DispatchCtx(context.Background(), event)
+}
+
+// DispatchCtx runs every handler subscribed to the event. Failures are
+// returned as a *DispatchError unless the RouteToHook policy is used.
+func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event Event) error {
+	var failures []*HandlerError
+	ctx = ContextWithEvent(ctx, event)
+	for i, sub := range d.snapshot().subscribers(event) {
+		// Skip subscriptions removed after the snapshot was taken
+		if sub.removed.Load() {
+			continue
+		}
+		if sub.once {
+			if !sub.fired.CompareAndSwap(false, true) {
+				continue
+			}
+			sub.Unsubscribe()
+		}
+
+		if attempts, err := sub.call(ctx, event); err != nil {
+			failure := &HandlerError{
+				EventID:   event.EventID(),
+				EventType: event.EventType(),
+				Handler:   i,
+				Attempts:  attempts,
+				Err:       err,
+			}
+			if d.ErrorPolicy == RouteToHook {
+				reportError(d.ErrorHook, ctx, event, failure)
+				continue
+			}
+			failures = append(failures, failure)
+			if d.ErrorPolicy == StopOnFirstError {
+				break
+			}
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return &DispatchError{EventID: event.EventID(), Failures: failures}
+}
@@ -0,0 +1,453 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type UserCreatedEvent struct {
+	BaseEvent
+	Name string `json:"name"`
+}
+
+func NewUserCreatedEvent(userID, name string) *UserCreatedEvent {
+	return &UserCreatedEvent{
+		BaseEvent: NewBaseEvent("UserCreated", userID, 1),
+		Name:      name,
+	}
+}
+
+type UserDeletedEvent struct {
+	BaseEvent
+}
+
+func NewUserDeletedEvent(userID string) *UserDeletedEvent {
+	return &UserDeletedEvent{BaseEvent: NewBaseEvent("UserDeleted", userID, 1)}
+}
+
+func TestInMemoryDispatcher_Dispatch(t *testing.T) {
+	t.Run("delivers to handlers of the event type in order", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			calls = append(calls, "first")
+			return nil, nil
+		})
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			calls = append(calls, "second")
+			return nil, nil
+		})
+		d.Subscribe(&UserDeletedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			calls = append(calls, "deleted")
+			return nil, nil
+		})
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+			t.Errorf("expected [first second], got %v", calls)
+		}
+	})
+
+	t.Run("passes context to handlers", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var got any
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			got = ctx.Value(testContextKey)
+			return nil, nil
+		})
+
+		ctx := context.WithValue(context.Background(), testContextKey, "value")
+		d.DispatchCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+
+		if got != "value" {
+			t.Errorf("expected context value 'value', got %v", got)
+		}
+	})
+
+	t.Run("ignores events without subscribers", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		d.Dispatch(NewUserDeletedEvent("user-1"))
+	})
+}
+
+func TestInMemoryDispatcher_Subscription(t *testing.T) {
+	counter := func(n *int) EventHandlerFunc {
+		return func(ctx context.Context, e Event) (any, error) {
+			*n++
+			return nil, nil
+		}
+	}
+
+	t.Run("unsubscribe stops delivery", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var kept, removed int
+		d.Subscribe(&UserCreatedEvent{}, counter(&kept))
+		sub := d.Subscribe(&UserCreatedEvent{}, counter(&removed))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if kept != 2 || removed != 1 {
+			t.Errorf("expected 2 and 1 deliveries, got %d and %d", kept, removed)
+		}
+
+		if sub.Active() {
+			t.Error("expected subscription to be inactive")
+		}
+
+		if got := len(d.snapshot().byType[reflect.TypeOf(UserCreatedEvent{})]); got != 1 {
+			t.Errorf("expected 1 remaining subscription, got %d", got)
+		}
+	})
+
+	t.Run("subscribe once delivers a single event", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls int
+		sub := d.SubscribeOnce(&UserCreatedEvent{}, counter(&calls))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Dispatch(NewUserCreatedEvent("user-2", "Jane"))
+
+		if calls != 1 {
+			t.Errorf("expected 1 delivery, got %d", calls)
+		}
+
+		if sub.Active() {
+			t.Error("expected subscription to be removed after firing")
+		}
+	})
+
+	t.Run("subscribe once fires once under concurrent dispatch", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls atomic.Int64
+		d.SubscribeOnce(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			calls.Add(1)
+			return nil, nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+			}()
+		}
+		wg.Wait()
+
+		if calls.Load() != 1 {
+			t.Errorf("expected 1 delivery, got %d", calls.Load())
+		}
+	})
+
+	t.Run("context cancellation removes subscription", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls int
+		ctx, cancel := context.WithCancel(context.Background())
+		sub := d.SubscribeCtx(ctx, &UserCreatedEvent{}, counter(&calls))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		cancel()
+
+		deadline := time.Now().Add(time.Second)
+		for sub.Active() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if sub.Active() {
+			t.Fatal("expected subscription to be removed after cancel")
+		}
+
+		if calls != 1 {
+			t.Errorf("expected 1 delivery, got %d", calls)
+		}
+	})
+
+	t.Run("cancelled context never subscribes", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls int
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		sub := d.SubscribeCtx(ctx, &UserCreatedEvent{}, counter(&calls))
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if sub.Active() || calls != 0 {
+			t.Errorf("expected inactive subscription and no deliveries, got %t and %d", sub.Active(), calls)
+		}
+	})
+
+	// Run with -race to verify the watcher and SubscribeCtx do not race
+	t.Run("cancellation concurrent with subscribing", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		subs := make([]*Subscription, 100)
+		var wg sync.WaitGroup
+		for i := range subs {
+			ctx, cancel := context.WithCancel(context.Background())
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cancel()
+			}()
+			subs[i] = d.SubscribeCtx(ctx, &UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+				return nil, nil
+			})
+		}
+		wg.Wait()
+
+		deadline := time.Now().Add(time.Second)
+		for _, sub := range subs {
+			for sub.Active() && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if sub.Active() {
+				t.Fatal("expected every subscription to be removed")
+			}
+		}
+	})
+}
+
+func TestInMemoryDispatcher_Matching(t *testing.T) {
+	recorder := func(name string, calls *[]string) EventHandlerFunc {
+		return func(ctx context.Context, e Event) (any, error) {
+			*calls = append(*calls, name)
+			return nil, nil
+		}
+	}
+
+	t.Run("subscribes by value and receives pointer events", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		d.Subscribe(UserCreatedEvent{}, recorder("value", &calls))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if !reflect.DeepEqual(calls, []string{"value"}) {
+			t.Errorf("expected [value], got %v", calls)
+		}
+	})
+
+	t.Run("subscribes by event type name", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		d.SubscribeType("UserDeleted", recorder("deleted", &calls))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Dispatch(NewUserDeletedEvent("user-1"))
+
+		if !reflect.DeepEqual(calls, []string{"deleted"}) {
+			t.Errorf("expected [deleted], got %v", calls)
+		}
+	})
+
+	t.Run("subscribes by glob pattern", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		if _, err := d.SubscribePattern("User.*", recorder("dotted", &calls)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := d.SubscribePattern("User*", recorder("prefix", &calls)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Dispatch(&UserCreatedEvent{BaseEvent: NewBaseEvent("User.Renamed", "user-1", 1)})
+
+		expected := []string{"prefix", "dotted", "prefix"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("expected %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("rejects invalid pattern", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		if _, err := d.SubscribePattern("User[", recorder("bad", new([]string))); err == nil {
+			t.Error("expected error for invalid pattern, got nil")
+		}
+	})
+
+	t.Run("catch-all and predicate subscribers keep subscription order", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		d.SubscribeAll(recorder("all", &calls))
+		d.Subscribe(&UserCreatedEvent{}, recorder("typed", &calls))
+		d.SubscribeFunc(MatchAggregate("user-1"), recorder("aggregate", &calls))
+		d.SubscribeFunc(MatchAggregate("user-2"), recorder("other", &calls))
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		d.Dispatch(NewUserDeletedEvent("user-2"))
+
+		expected := []string{"all", "typed", "aggregate", "all", "other"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("expected %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("unsubscribes matcher subscriptions", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls []string
+		sub := d.SubscribeAll(recorder("all", &calls))
+		sub.Unsubscribe()
+
+		d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if len(calls) != 0 || len(d.snapshot().matchers) != 0 {
+			t.Errorf("expected no deliveries and no matchers, got %v and %d", calls, len(d.snapshot().matchers))
+		}
+	})
+}
+
+func TestInMemoryDispatcher_ErrorPolicy(t *testing.T) {
+	errFirst := errors.New("first failed")
+	errSecond := errors.New("second failed")
+	newDispatcher := func(calls *int) *InMemoryDispatcher {
+		d := NewInMemoryDispatcher()
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			*calls++
+			return nil, errFirst
+		})
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			*calls++
+			return nil, nil
+		})
+		d.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			*calls++
+			return nil, errSecond
+		})
+		return d
+	}
+
+	t.Run("continue on error joins every failure", func(t *testing.T) {
+		var calls int
+		d := newDispatcher(&calls)
+		event := NewUserCreatedEvent("user-1", "John")
+
+		err := d.Dispatch(event)
+
+		var dispatchErr *DispatchError
+		if !errors.As(err, &dispatchErr) {
+			t.Fatalf("expected *DispatchError, got %v", err)
+		}
+
+		if calls != 3 {
+			t.Errorf("expected 3 handler calls, got %d", calls)
+		}
+
+		if dispatchErr.EventID != event.EventID() {
+			t.Errorf("expected event ID %s, got %s", event.EventID(), dispatchErr.EventID)
+		}
+
+		if len(dispatchErr.Failures) != 2 {
+			t.Fatalf("expected 2 failures, got %d", len(dispatchErr.Failures))
+		}
+
+		if dispatchErr.Failures[0].Handler != 0 || dispatchErr.Failures[1].Handler != 2 {
+			t.Errorf("expected failed handlers 0 and 2, got %d and %d",
+				dispatchErr.Failures[0].Handler, dispatchErr.Failures[1].Handler)
+		}
+
+		if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+			t.Errorf("expected error to wrap both handler errors, got %v", err)
+		}
+	})
+
+	t.Run("stop on first error skips remaining handlers", func(t *testing.T) {
+		var calls int
+		d := newDispatcher(&calls)
+		d.ErrorPolicy = StopOnFirstError
+
+		err := d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+
+		if !errors.Is(err, errFirst) {
+			t.Errorf("expected %v, got %v", errFirst, err)
+		}
+
+		if errors.Is(err, errSecond) {
+			t.Errorf("expected second failure not to be reported, got %v", err)
+		}
+
+		if calls != 1 {
+			t.Errorf("expected 1 handler call, got %d", calls)
+		}
+	})
+
+	t.Run("route to hook reports failures and returns nil", func(t *testing.T) {
+		var calls int
+		var hooked []error
+		d := newDispatcher(&calls)
+		d.ErrorPolicy = RouteToHook
+		d.ErrorHook = func(ctx context.Context, event Event, err error) {
+			hooked = append(hooked, err)
+		}
+
+		if err := d.Dispatch(NewUserCreatedEvent("user-1", "John")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(hooked) != 2 {
+			t.Fatalf("expected 2 hooked errors, got %d", len(hooked))
+		}
+
+		var handlerErr *HandlerError
+		if !errors.As(hooked[0], &handlerErr) || handlerErr.EventType != "UserCreated" {
+			t.Errorf("expected *HandlerError for UserCreated, got %v", hooked[0])
+		}
+	})
+}
+
+// Run with -race to verify the copy-on-write subscription table
+func TestInMemoryDispatcher_Concurrent(t *testing.T) {
+	d := NewInMemoryDispatcher()
+	var delivered atomic.Int64
+	handler := func(ctx context.Context, e Event) (any, error) {
+		delivered.Add(1)
+		return nil, nil
+	}
+	d.Subscribe(&UserCreatedEvent{}, handler)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				d.Subscribe(&UserCreatedEvent{}, handler)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				d.Subscribe(&UserDeletedEvent{}, handler).Unsubscribe()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(d.snapshot().byType[reflect.TypeOf(UserCreatedEvent{})]); got != 801 {
+		t.Errorf("expected 801 handlers, got %d", got)
+	}
+
+	if got := len(d.snapshot().byType[reflect.TypeOf(UserDeletedEvent{})]); got != 0 {
+		t.Errorf("expected no UserDeletedEvent handlers, got %d", got)
+	}
+
+	if delivered.Load() < 800 {
+		t.Errorf("expected at least 800 deliveries, got %d", delivered.Load())
+	}
+}
@@ -0,0 +1,80 @@
+package gocmdevt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ErrorPolicy decides how failures during event dispatch and emission are handled
+type ErrorPolicy int
+
+const (
+	// ContinueOnError runs every step and returns all failures joined together
+	ContinueOnError ErrorPolicy = iota
+	// StopOnFirstError returns as soon as one step fails
+	StopOnFirstError
+	// RouteToHook passes each failure to the error hook and reports success
+	RouteToHook
+)
+
+// ErrorHook receives failures when the RouteToHook policy is used, and
+// failures of background components. event is nil for failures not tied to
+// an event.
+type ErrorHook func(ctx context.Context, event Event, err error)
+
+// HandlerError is the failure of a single event handler
+type HandlerError struct {
+	EventID   string
+	EventType string
+	// Handler is the position of the failed handler in subscription order
+	Handler int
+	// Attempts is the number of calls made, more than one when retried
+	Attempts int
+	Err      error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("event %s (%s) handler %d: %v", e.EventID, e.EventType, e.Handler, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// DispatchError aggregates the handler failures of a single dispatch
+type DispatchError struct {
+	EventID  string
+	Failures []*HandlerError
+}
+
+func (e *DispatchError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		parts[i] = f.Error()
+	}
+	return fmt.Sprintf("dispatch of event %s failed: %s", e.EventID, strings.Join(parts, "; "))
+}
+
+func (e *DispatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f
+	}
+	return errs
+}
+
+// DeliveryError reports events that were logged but whose dispatch or
+// publishing failed. Retrying the emission would store them twice.
+type DeliveryError struct {
+	AggregateID string
+	Err         error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("events of aggregate %s were stored but not delivered: %v", e.AggregateID, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
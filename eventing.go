@@ -2,10 +2,9 @@ package gocmdevt
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
-	"reflect"
 	"time"
 )
 
@@ -39,28 +38,55 @@ type BaseEvent struct {
 	Time      time.Time `json:"time"`
 	Aggregate string    `json:"aggregate_id"`
 	Version   int       `json:"version"`
+	// Correlation identifies the command chain the event belongs to
+	Correlation string `json:"correlation_id,omitempty"`
+	// Causation is the ID of the command or event that caused this one
+	Causation string `json:"causation_id,omitempty"`
+	// Principal, Tenant and Headers are copied from the emitting command
+	Principal string            `json:"principal,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
 }
 
-func NewBaseEvent(eventType, aggregateID string, version int) BaseEvent {
+// EventOption customizes NewBaseEvent
+type EventOption func(*eventOptions)
+
+type eventOptions struct {
+	ids   IDGenerator
+	clock Clock
+}
+
+// WithIDGenerator makes NewBaseEvent take the event ID from g instead of
+// the default generator
+func WithIDGenerator(g IDGenerator) EventOption {
+	return func(o *eventOptions) {
+		o.ids = g
+	}
+}
+
+// WithClock makes NewBaseEvent take the event time from c instead of the
+// default clock
+func WithClock(c Clock) EventOption {
+	return func(o *eventOptions) {
+		o.clock = c
+	}
+}
+
+func NewBaseEvent(eventType, aggregateID string, version int, opts ...EventOption) BaseEvent {
+	options := eventOptions{ids: DefaultIDGenerator(), clock: DefaultClock()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return BaseEvent{
-		ID:        generateEventID(),
+		ID:        options.ids.NewID(),
 		Type:      eventType,
-		Time:      time.Now().UTC(),
+		Time:      options.clock.Now().UTC(),
 		Aggregate: aggregateID,
 		Version:   version,
 	}
 }
 
-// generateEventID creates a unique identifier for events
-func generateEventID() string {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		// Fallback to timestamp-based ID if random generation fails
-		return time.Now().Format("20060102150405") + "-" + time.Now().Format("000000")
-	}
-	return hex.EncodeToString(bytes)
-}
-
 func (e BaseEvent) EventID() string {
 	return e.ID
 }
@@ -81,13 +107,47 @@ func (e BaseEvent) EventVersion() int {
 	return e.Version
 }
 
+func (e BaseEvent) CorrelationID() string {
+	return e.Correlation
+}
+
+func (e BaseEvent) CausationID() string {
+	return e.Causation
+}
+
+func (e *BaseEvent) SetCausality(correlationID, causationID string) {
+	e.Correlation = correlationID
+	e.Causation = causationID
+}
+
+// StampCommand copies the metadata of the emitting command, keeping values
+// the event already has.
+func (e *BaseEvent) StampCommand(meta CommandMetadata) {
+	if e.Principal == "" {
+		e.Principal = meta.Principal
+	}
+	if e.Tenant == "" {
+		e.Tenant = meta.Tenant
+	}
+	e.Headers = mergeHeaders(e.Headers, meta.Headers)
+}
+
+// Payload returns the event metadata. A method cannot see the struct
+// embedding BaseEvent, so an event that does not implement Payload with
+// PayloadOf returns only this metadata; PayloadOf(event) returns the fields
+// of any event, keyed by their JSON names.
 func (e BaseEvent) Payload() map[string]interface{} {
 	return map[string]interface{}{
-		"id":        e.ID,
-		"type":      e.Type,
-		"time":      e.Time,
-		"aggregate": e.Aggregate,
-		"version":   e.Version,
+		"id":          e.ID,
+		"type":        e.Type,
+		"time":        e.Time,
+		"aggregate":   e.Aggregate,
+		"version":     e.Version,
+		"correlation": e.Correlation,
+		"causation":   e.Causation,
+		"principal":   e.Principal,
+		"tenant":      e.Tenant,
+		"headers":     e.Headers,
 	}
 }
 
@@ -98,15 +158,43 @@ type EventLogWriter interface {
 
 // Dispatcher is an interface for dispatching events to handlers
 type Dispatcher interface {
-	Dispatch(event Event)
-	DispatchCtx(ctx context.Context, event Event)
+	Dispatch(event Event) error
+	DispatchCtx(ctx context.Context, event Event) error
+}
+
+// DispatcherFunc adapts a function to a Dispatcher, for delivering events
+// somewhere other than in-process handlers, such as an external publisher
+// fed by an OutboxRelay.
+type DispatcherFunc func(ctx context.Context, event Event) error
+
+func (f DispatcherFunc) Dispatch(event Event) error {
+	return f(context.Background(), event)
+}
+
+func (f DispatcherFunc) DispatchCtx(ctx context.Context, event Event) error {
+	return f(ctx, event)
 }
 
 // EventEmitter handles event emission with logging and dispatching
 type EventEmitter struct {
 	LogWriter  EventLogWriter
 	Dispatcher Dispatcher
-	// Queue       *QueuePublisher
+
+	// Publisher, when set, also receives dispatched events, turned into
+	// messages by MessageMapper, for other services to consume. Events
+	// staged in an Outbox skip it; relay them to a PublisherDispatcher to
+	// publish them once committed.
+	Publisher     Publisher
+	MessageMapper MessageMapper
+
+	// Outbox, when set, receives logged events instead of the Dispatcher.
+	// An OutboxRelay delivers them once the staging is committed.
+	Outbox Outbox
+
+	// ErrorPolicy decides whether a failed log write stops dispatching
+	ErrorPolicy ErrorPolicy
+	// ErrorHook receives failures under the RouteToHook policy
+	ErrorHook ErrorHook
 }
 
 func NewEventEmitter(logWriter EventLogWriter, dispatcher Dispatcher) *EventEmitter {
@@ -116,77 +204,184 @@ func NewEventEmitter(logWriter EventLogWriter, dispatcher Dispatcher) *EventEmit
 	}
 }
 
-func (e *EventEmitter) Emit(event Event) {
-	// Log to DB
-	if err := e.LogWriter.Write(event); err != nil {
-		log.Printf("audit log failed: %v", err)
-	}
+func (e *EventEmitter) Emit(event Event) error {
+	return e.EmitCtx(context.Background(), event)
+}
 
-	// In-process dispatch
-	e.Dispatcher.Dispatch(event)
+// EmitCtx logs and dispatches event. Events without a correlation ID take
+// the correlation and causation IDs of ctx, and events embedding BaseEvent
+// get the metadata of the command being handled. With a Publisher the
+// dispatched event is published as well.
+//
+// With an Outbox the event is logged and staged in ctx's transaction
+// instead, and any failure is returned. Within a UnitOfWork the event is
+// buffered until the command succeeds.
+func (e *EventEmitter) EmitCtx(ctx context.Context, event Event) error {
+	var failures []emitFailure
+	stampEvent(ctx, event)
+
+	if unit := unitFromContext(ctx); unit != nil {
+		emit := func() error { return e.EmitCtx(withoutUnit(ctx), event) }
+		if unit.buffer(unitEntry{emit: emit}) {
+			return nil
+		}
+	}
 
-	// Optional async queue
-	// e.Queue.Publish(event)
-}
+	if e.Outbox != nil {
+		if err := e.writeLog(ctx, event); err != nil {
+			return fmt.Errorf("audit log failed for event %s: %w", event.EventID(), err)
+		}
+		return e.stage(ctx, event)
+	}
 
-func (e *EventEmitter) EmitCtx(ctx context.Context, event Event) {
 	// Log to DB
 	if err := e.LogWriter.Write(event); err != nil {
-		log.Printf("audit log failed: %v", err)
+		err = fmt.Errorf("audit log failed for event %s: %w", event.EventID(), err)
+		if e.ErrorPolicy == StopOnFirstError {
+			return err
+		}
+		failures = append(failures, emitFailure{event, err})
 	}
 
-	// In-process dispatch
-	e.Dispatcher.DispatchCtx(ctx, event)
-
-	// Optional async queue
-	// e.Queue.Publish(event)
+	return e.dispatch(ctx, failures, event)
 }
 
-// ###
+// EmitVersioned logs the events of one aggregate and dispatches them. When the
+// LogWriter is an EventAppender the events are appended together, expecting
+// the aggregate to be at expectedVersion; if that fails nothing is dispatched.
+// Other log writers get one Write per event and no version check.
+// Failures after the events were logged are returned as a *DeliveryError.
+// With an Outbox the events are staged instead of dispatched. Within a
+// UnitOfWork they are buffered until the command succeeds, so a version
+// conflict surfaces as the command's error.
+func (e *EventEmitter) EmitVersioned(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) error {
+	for _, event := range events {
+		stampEvent(ctx, event)
+	}
 
-type ConsoleEventLogger struct{}
+	if unit := unitFromContext(ctx); unit != nil {
+		emit := func() error { return e.EmitVersioned(withoutUnit(ctx), aggregateID, expectedVersion, events...) }
+		if unit.buffer(unitEntry{emit: emit}) {
+			return nil
+		}
+	}
 
-func (l *ConsoleEventLogger) Write(event Event) error {
-	log.Printf("Event logged: %s at %s. ID: %s", event.EventType(), event.EventTime(), event.EventID())
+	if appender, ok := e.LogWriter.(EventAppender); ok {
+		if _, err := appender.Append(ctx, aggregateID, expectedVersion, events...); err != nil {
+			return fmt.Errorf("append events for aggregate %s: %w", aggregateID, err)
+		}
+	} else if e.LogWriter != nil {
+		for _, event := range events {
+			if err := e.LogWriter.Write(event); err != nil {
+				return fmt.Errorf("audit log failed for event %s: %w", event.EventID(), err)
+			}
+		}
+	}
+
+	if e.Outbox != nil {
+		return e.stage(ctx, events...)
+	}
+	if err := e.dispatch(ctx, nil, events...); err != nil {
+		return &DeliveryError{AggregateID: aggregateID, Err: err}
+	}
 	return nil
 }
 
-func NewConsoleEventLogger() *ConsoleEventLogger {
-	return &ConsoleEventLogger{}
+// writeLog logs event in outbox mode, through Append when possible so a
+// store can join the transaction in ctx. There the LogWriter is optional.
+func (e *EventEmitter) writeLog(ctx context.Context, event Event) error {
+	switch w := e.LogWriter.(type) {
+	case nil:
+		return nil
+	case EventAppender:
+		_, err := w.Append(ctx, event.AggregateID(), AnyVersion, event)
+		return err
+	default:
+		return w.Write(event)
+	}
 }
 
-// ###
-
-type InMemoryDispatcher struct {
-	handlers map[reflect.Type][]EventHandlerFunc
+// stage hands logged events to the outbox
+func (e *EventEmitter) stage(ctx context.Context, events ...Event) error {
+	if err := e.Outbox.Stage(ctx, events...); err != nil {
+		return fmt.Errorf("stage events in outbox: %w", err)
+	}
+	return nil
 }
 
-func NewInMemoryDispatcher() *InMemoryDispatcher {
-	return &InMemoryDispatcher{
-		handlers: make(map[reflect.Type][]EventHandlerFunc),
-	}
+// stampEvent records the command chain and command of ctx in event
+func stampEvent(ctx context.Context, event Event) {
+	stampCausality(ctx, event)
+	stampCommand(ctx, event)
 }
 
-func (d *InMemoryDispatcher) Subscribe(event Event, handler EventHandlerFunc) {
-	eventType := reflect.TypeOf(event)
-	d.handlers[eventType] = append(d.handlers[eventType], handler)
+// emitFailure pairs a failed step with its event for error hooks
+type emitFailure struct {
+	event Event
+	err   error
 }
 
-func (d *InMemoryDispatcher) Dispatch(event Event) {
-	ctx := context.Background()
-	eventType := reflect.TypeOf(event)
-	if handlers, exists := d.handlers[eventType]; exists {
-		for _, handler := range handlers {
-			handler(ctx, event)
+// dispatch delivers logged events and applies the error policy to the
+// failures so far and any dispatch failures
+func (e *EventEmitter) dispatch(ctx context.Context, failures []emitFailure, events ...Event) error {
+	// In-process dispatch
+	for _, event := range events {
+		if err := e.Dispatcher.DispatchCtx(ctx, event); err != nil {
+			failures = append(failures, emitFailure{event, err})
+			if e.ErrorPolicy == StopOnFirstError {
+				break
+			}
 		}
 	}
-}
 
-func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event Event) {
-	eventType := reflect.TypeOf(event)
-	if handlers, exists := d.handlers[eventType]; exists {
-		for _, handler := range handlers {
-			handler(ctx, event)
+	// Fan out to the broker
+	if e.Publisher != nil && (e.ErrorPolicy != StopOnFirstError || len(failures) == 0) {
+		for _, event := range events {
+			if err := e.publish(ctx, event); err != nil {
+				failures = append(failures, emitFailure{event, err})
+				if e.ErrorPolicy == StopOnFirstError {
+					break
+				}
+			}
 		}
 	}
+
+	if e.ErrorPolicy == RouteToHook {
+		for _, f := range failures {
+			reportError(e.ErrorHook, ctx, f.event, f.err)
+		}
+		return nil
+	}
+
+	errs := make([]error, len(failures))
+	for i, f := range failures {
+		errs[i] = f.err
+	}
+	return errors.Join(errs...)
+}
+
+// reportError passes err to hook, falling back to the standard logger
+func reportError(hook ErrorHook, ctx context.Context, event Event, err error) {
+	if hook == nil && event == nil {
+		log.Printf("event processing failed: %v", err)
+		return
+	}
+	if hook == nil {
+		log.Printf("event %s failed: %v", event.EventID(), err)
+		return
+	}
+	hook(ctx, event, err)
+}
+
+// ###
+
+type ConsoleEventLogger struct{}
+
+func (l *ConsoleEventLogger) Write(event Event) error {
+	log.Printf("Event logged: %s at %s. ID: %s", event.EventType(), event.EventTime(), event.EventID())
+	return nil
+}
+
+func NewConsoleEventLogger() *ConsoleEventLogger {
+	return &ConsoleEventLogger{}
 }
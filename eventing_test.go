@@ -0,0 +1,100 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// recordingLogWriter records written events and fails with err when set
+type recordingLogWriter struct {
+	events []Event
+	err    error
+}
+
+func (w *recordingLogWriter) Write(event Event) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.events = append(w.events, event)
+	return nil
+}
+
+func TestEventEmitter_Emit(t *testing.T) {
+	errLog := errors.New("disk full")
+	errHandler := errors.New("handler failed")
+
+	t.Run("logs and dispatches event", func(t *testing.T) {
+		logWriter := &recordingLogWriter{}
+		dispatcher := NewInMemoryDispatcher()
+		var dispatched int
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			dispatched++
+			return nil, nil
+		})
+		emitter := NewEventEmitter(logWriter, dispatcher)
+
+		if err := emitter.Emit(NewUserCreatedEvent("user-1", "John")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(logWriter.events) != 1 || dispatched != 1 {
+			t.Errorf("expected 1 logged and 1 dispatched event, got %d and %d", len(logWriter.events), dispatched)
+		}
+	})
+
+	t.Run("continue on error joins log and handler failures", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			return nil, errHandler
+		})
+		emitter := NewEventEmitter(&recordingLogWriter{err: errLog}, dispatcher)
+
+		err := emitter.Emit(NewUserCreatedEvent("user-1", "John"))
+
+		if !errors.Is(err, errLog) || !errors.Is(err, errHandler) {
+			t.Errorf("expected log and handler errors, got %v", err)
+		}
+
+		var dispatchErr *DispatchError
+		if !errors.As(err, &dispatchErr) {
+			t.Errorf("expected *DispatchError in %v", err)
+		}
+	})
+
+	t.Run("stop on first error skips dispatch after log failure", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		dispatched := false
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			dispatched = true
+			return nil, nil
+		})
+		emitter := NewEventEmitter(&recordingLogWriter{err: errLog}, dispatcher)
+		emitter.ErrorPolicy = StopOnFirstError
+
+		if err := emitter.Emit(NewUserCreatedEvent("user-1", "John")); !errors.Is(err, errLog) {
+			t.Errorf("expected %v, got %v", errLog, err)
+		}
+
+		if dispatched {
+			t.Error("expected event not to be dispatched")
+		}
+	})
+
+	t.Run("route to hook reports failures and returns nil", func(t *testing.T) {
+		var hooked []error
+		emitter := NewEventEmitter(&recordingLogWriter{err: errLog}, NewInMemoryDispatcher())
+		emitter.ErrorPolicy = RouteToHook
+		emitter.ErrorHook = func(ctx context.Context, event Event, err error) {
+			hooked = append(hooked, err)
+		}
+
+		if err := emitter.Emit(NewUserCreatedEvent("user-1", "John")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(hooked) != 1 || !errors.Is(hooked[0], errLog) {
+			t.Errorf("expected hooked log error, got %v", hooked)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// AnyVersion skips the optimistic concurrency check on Append
+	AnyVersion = -1
+	// NoStream expects the aggregate to have no events yet
+	NoStream = 0
+)
+
+// ErrConcurrencyConflict is matched by every *ConcurrencyError
+var ErrConcurrencyConflict = errors.New("concurrency conflict")
+
+// ConcurrencyError is returned by Append when the aggregate's current version
+// differs from the expected version
+type ConcurrencyError struct {
+	AggregateID string
+	Expected    int
+	Actual      int
+}
+
+func (e *ConcurrencyError) Error() string {
+	return fmt.Sprintf("%v: aggregate %s is at version %d, expected %d", ErrConcurrencyConflict, e.AggregateID, e.Actual, e.Expected)
+}
+
+func (e *ConcurrencyError) Unwrap() error {
+	return ErrConcurrencyConflict
+}
+
+// StoredEvent is an event together with its position in the store
+type StoredEvent struct {
+	Event Event
+	// Version is the 1-based position of the event in its aggregate's stream
+	Version int
+	// Position is the 1-based position of the event across all aggregates
+	Position int64
+}
+
+// EventAppender appends events to an aggregate's stream with optimistic concurrency.
+type EventAppender interface {
+	// Append adds events to the aggregate's stream if its current version is
+	// expectedVersion (or expectedVersion is AnyVersion) and returns the new version.
+	Append(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) (int, error)
+}
+
+// EventStore persists events per aggregate with optimistic concurrency.
+type EventStore interface {
+	EventAppender
+
+	// Load returns the aggregate's events starting at fromVersion.
+	Load(ctx context.Context, aggregateID string, fromVersion int) ([]StoredEvent, error)
+
+	// LoadAll returns up to limit events across all aggregates starting at
+	// fromPosition. A limit of zero or less means no limit.
+	LoadAll(ctx context.Context, fromPosition int64, limit int) ([]StoredEvent, error)
+}
+
+// checkAppend validates an append against the aggregate's current version
+func checkAppend(aggregateID string, expectedVersion, current int, events []Event) error {
+	if expectedVersion != AnyVersion && expectedVersion != current {
+		return &ConcurrencyError{AggregateID: aggregateID, Expected: expectedVersion, Actual: current}
+	}
+	for _, event := range events {
+		if event.AggregateID() != aggregateID {
+			return fmt.Errorf("event %s belongs to aggregate %q, not %q", event.EventID(), event.AggregateID(), aggregateID)
+		}
+	}
+	return nil
+}
+
+// ###
+
+// EventStoreWriter adapts an EventStore into an EventLogWriter so an
+// EventEmitter can persist to it. Events are appended without a version check.
+type EventStoreWriter struct {
+	Store EventStore
+}
+
+func NewEventStoreWriter(store EventStore) *EventStoreWriter {
+	return &EventStoreWriter{Store: store}
+}
+
+func (w *EventStoreWriter) Write(event Event) error {
+	_, err := w.Store.Append(context.Background(), event.AggregateID(), AnyVersion, event)
+	return err
+}
+
+// Append appends to the underlying store, so EventEmitter.EmitVersioned keeps its version check.
+func (w *EventStoreWriter) Append(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) (int, error) {
+	return w.Store.Append(ctx, aggregateID, expectedVersion, events...)
+}
+
+// ###
+
+// InMemoryEventStore is an EventStore kept in process memory
+type InMemoryEventStore struct {
+	mu          sync.RWMutex
+	events      []StoredEvent
+	byAggregate map[string][]int // indexes into events
+}
+
+func NewInMemoryEventStore() *InMemoryEventStore {
+	return &InMemoryEventStore{
+		byAggregate: make(map[string][]int),
+	}
+}
+
+func (s *InMemoryEventStore) Append(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := len(s.byAggregate[aggregateID])
+	if err := checkAppend(aggregateID, expectedVersion, current, events); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		current++
+		s.byAggregate[aggregateID] = append(s.byAggregate[aggregateID], len(s.events))
+		s.events = append(s.events, StoredEvent{
+			Event:    event,
+			Version:  current,
+			Position: int64(len(s.events) + 1),
+		})
+	}
+	return current, nil
+}
+
+func (s *InMemoryEventStore) Load(ctx context.Context, aggregateID string, fromVersion int) ([]StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	indexes := s.byAggregate[aggregateID]
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	if fromVersion > len(indexes) {
+		return nil, nil
+	}
+
+	stored := make([]StoredEvent, 0, len(indexes)-fromVersion+1)
+	for _, i := range indexes[fromVersion-1:] {
+		stored = append(stored, s.events[i])
+	}
+	return stored, nil
+}
+
+func (s *InMemoryEventStore) LoadAll(ctx context.Context, fromPosition int64, limit int) ([]StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if fromPosition < 1 {
+		fromPosition = 1
+	}
+	if fromPosition > int64(len(s.events)) {
+		return nil, nil
+	}
+
+	rest := s.events[fromPosition-1:]
+	if limit > 0 && limit < len(rest) {
+		rest = rest[:limit]
+	}
+	return append([]StoredEvent(nil), rest...), nil
+}
@@ -0,0 +1,33 @@
+package gocmdevt_test
+
+import (
+	"context"
+	"testing"
+
+	gocmdevt "github.com/leviplj/go-cmd-evt"
+	"github.com/leviplj/go-cmd-evt/eventstoretest"
+)
+
+func TestInMemoryEventStore(t *testing.T) {
+	eventstoretest.Run(t, func(t *testing.T) gocmdevt.EventStore {
+		return gocmdevt.NewInMemoryEventStore()
+	})
+}
+
+func TestEventStoreWriter(t *testing.T) {
+	store := gocmdevt.NewInMemoryEventStore()
+	emitter := gocmdevt.NewEventEmitter(gocmdevt.NewEventStoreWriter(store), gocmdevt.NewInMemoryDispatcher())
+
+	if err := emitter.Emit(eventstoretest.NewNoteAdded("note-1", "a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored, err := store.Load(context.Background(), "note-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stored) != 1 || stored[0].Version != 1 {
+		t.Errorf("expected 1 event at version 1, got %v", stored)
+	}
+}
@@ -0,0 +1,224 @@
+// Package eventstoretest provides a conformance suite for gocmdevt.EventStore
+// implementations.
+package eventstoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	gocmdevt "github.com/leviplj/go-cmd-evt"
+)
+
+// NoteAdded is the event type appended by the suite
+type NoteAdded struct {
+	gocmdevt.BaseEvent
+	Text string `json:"text"`
+}
+
+func NewNoteAdded(aggregateID, text string) *NoteAdded {
+	return &NoteAdded{
+		BaseEvent: gocmdevt.NewBaseEvent("NoteAdded", aggregateID, 1),
+		Text:      text,
+	}
+}
+
+// Run runs the conformance suite. newStore must return an empty store for
+// every call.
+func Run(t *testing.T, newStore func(t *testing.T) gocmdevt.EventStore) {
+	ctx := context.Background()
+
+	t.Run("appends to a new stream", func(t *testing.T) {
+		store := newStore(t)
+
+		version, err := store.Append(ctx, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "a"), NewNoteAdded("note-1", "b"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if version != 2 {
+			t.Errorf("expected version 2, got %d", version)
+		}
+	})
+
+	t.Run("rejects unexpected version", func(t *testing.T) {
+		store := newStore(t)
+		mustAppend(t, store, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "a"))
+
+		_, err := store.Append(ctx, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "b"))
+		if !errors.Is(err, gocmdevt.ErrConcurrencyConflict) {
+			t.Fatalf("expected ErrConcurrencyConflict, got %v", err)
+		}
+
+		var conflict *gocmdevt.ConcurrencyError
+		if !errors.As(err, &conflict) || conflict.Actual != 1 || conflict.Expected != 0 {
+			t.Errorf("expected conflict at version 1 expecting 0, got %v", err)
+		}
+
+		stored, _ := store.Load(ctx, "note-1", 1)
+		if len(stored) != 1 {
+			t.Errorf("expected rejected append to add nothing, got %d events", len(stored))
+		}
+	})
+
+	t.Run("appends with any version", func(t *testing.T) {
+		store := newStore(t)
+		mustAppend(t, store, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "a"))
+
+		version, err := store.Append(ctx, "note-1", gocmdevt.AnyVersion, NewNoteAdded("note-1", "b"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if version != 2 {
+			t.Errorf("expected version 2, got %d", version)
+		}
+	})
+
+	t.Run("rejects events of another aggregate", func(t *testing.T) {
+		store := newStore(t)
+
+		if _, err := store.Append(ctx, "note-1", gocmdevt.AnyVersion, NewNoteAdded("note-2", "a")); err == nil {
+			t.Error("expected error for mismatched aggregate, got nil")
+		}
+	})
+
+	t.Run("loads a stream from a version", func(t *testing.T) {
+		store := newStore(t)
+		events := []gocmdevt.Event{NewNoteAdded("note-1", "a"), NewNoteAdded("note-1", "b"), NewNoteAdded("note-1", "c")}
+		mustAppend(t, store, "note-1", gocmdevt.NoStream, events...)
+		mustAppend(t, store, "note-2", gocmdevt.NoStream, NewNoteAdded("note-2", "x"))
+
+		stored, err := store.Load(ctx, "note-1", 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(stored) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(stored))
+		}
+
+		for i, s := range stored {
+			want := events[i+1]
+			if s.Version != i+2 {
+				t.Errorf("expected version %d, got %d", i+2, s.Version)
+			}
+			assertSameEvent(t, want, s.Event)
+		}
+	})
+
+	t.Run("loads nothing for unknown aggregate", func(t *testing.T) {
+		store := newStore(t)
+
+		stored, err := store.Load(ctx, "missing", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(stored) != 0 {
+			t.Errorf("expected no events, got %d", len(stored))
+		}
+	})
+
+	t.Run("loads all events by global position", func(t *testing.T) {
+		store := newStore(t)
+		mustAppend(t, store, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "a"))
+		mustAppend(t, store, "note-2", gocmdevt.NoStream, NewNoteAdded("note-2", "b"))
+		mustAppend(t, store, "note-1", 1, NewNoteAdded("note-1", "c"))
+
+		all, err := store.LoadAll(ctx, 1, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(all))
+		}
+
+		for i, s := range all {
+			if s.Position <= 0 || (i > 0 && s.Position <= all[i-1].Position) {
+				t.Errorf("expected increasing positions, got %d after %d", s.Position, all[max(i-1, 0)].Position)
+			}
+		}
+
+		if all[2].Event.AggregateID() != "note-1" || all[2].Version != 2 {
+			t.Errorf("expected third event to be note-1 version 2, got %s version %d", all[2].Event.AggregateID(), all[2].Version)
+		}
+
+		page, err := store.LoadAll(ctx, all[0].Position+1, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(page) != 1 || page[0].Position != all[1].Position {
+			t.Errorf("expected page with position %d, got %v", all[1].Position, page)
+		}
+	})
+
+	t.Run("allows one of concurrent appends with the same expected version", func(t *testing.T) {
+		store := newStore(t)
+		mustAppend(t, store, "note-1", gocmdevt.NoStream, NewNoteAdded("note-1", "a"))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var succeeded, conflicted int
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := store.Append(ctx, "note-1", 1, NewNoteAdded("note-1", fmt.Sprint(i)))
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					succeeded++
+				case errors.Is(err, gocmdevt.ErrConcurrencyConflict):
+					conflicted++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if succeeded != 1 || conflicted != 7 {
+			t.Errorf("expected 1 success and 7 conflicts, got %d and %d", succeeded, conflicted)
+		}
+	})
+
+	t.Run("honors cancelled context", func(t *testing.T) {
+		store := newStore(t)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if _, err := store.Append(cancelled, "note-1", gocmdevt.AnyVersion, NewNoteAdded("note-1", "a")); err == nil {
+			t.Error("expected error for cancelled context, got nil")
+		}
+	})
+}
+
+func mustAppend(t *testing.T, store gocmdevt.EventStore, aggregateID string, expectedVersion int, events ...gocmdevt.Event) {
+	t.Helper()
+	if _, err := store.Append(context.Background(), aggregateID, expectedVersion, events...); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+}
+
+func assertSameEvent(t *testing.T, want, got gocmdevt.Event) {
+	t.Helper()
+	if got.EventID() != want.EventID() || got.EventType() != want.EventType() || got.AggregateID() != want.AggregateID() {
+		t.Errorf("expected event %s %s %s, got %s %s %s",
+			want.EventID(), want.EventType(), want.AggregateID(),
+			got.EventID(), got.EventType(), got.AggregateID())
+	}
+
+	if !got.EventTime().Equal(want.EventTime()) {
+		t.Errorf("expected event time %v, got %v", want.EventTime(), got.EventTime())
+	}
+
+	if got.EventVersion() != want.EventVersion() {
+		t.Errorf("expected event version %d, got %d", want.EventVersion(), got.EventVersion())
+	}
+}
@@ -37,15 +37,7 @@ type OrderCreatedEvent struct {
 }
 
 func (e *OrderCreatedEvent) Payload() map[string]interface{} {
-	return map[string]interface{}{
-		"id":           e.ID,
-		"type":         e.Type,
-		"time":         e.Time,
-		"customer_id":  e.CustomerID,
-		"product_id":   e.ProductID,
-		"quantity":     e.Quantity,
-		"total_amount": e.TotalAmount,
-	}
+	return gocmdevt.PayloadOf(e)
 }
 
 func NewOrderCreatedEvent(orderID, customerID, productID string, quantity int, totalAmount float64) *OrderCreatedEvent {
@@ -108,18 +100,19 @@ func (d *InMemoryDispatcher) Subscribe(eventType reflect.Type, handler func(even
 	d.handlers[eventType] = append(d.handlers[eventType], handler)
 }
 
-func (d *InMemoryDispatcher) Dispatch(event gocmdevt.Event) {
+func (d *InMemoryDispatcher) Dispatch(event gocmdevt.Event) error {
 	ctx := context.Background()
-	d.DispatchCtx(ctx, event)
+	return d.DispatchCtx(ctx, event)
 }
 
-func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event gocmdevt.Event) {
+func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event gocmdevt.Event) error {
 	eventType := reflect.TypeOf(event)
 	if handlers, exists := d.handlers[eventType]; exists {
 		for _, handler := range handlers {
 			handler(event)
 		}
 	}
+	return nil
 }
 
 // Example module that handles order-related commands
@@ -161,7 +154,9 @@ func (m *OrderModule) createOrder(ctx context.Context, cmd gocmdevt.Command) (an
 
 	// Emit event after successful command handling
 	event := NewOrderCreatedEvent(orderID, createCmd.CustomerID, createCmd.ProductID, createCmd.Quantity, createCmd.TotalAmount)
-	m.eventEmitter.EmitCtx(context.TODO(), event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
@@ -184,7 +179,9 @@ func (m *OrderModule) processPayment(ctx context.Context, cmd gocmdevt.Command)
 
 	// Emit event after successful payment processing
 	event := NewPaymentProcessedEvent(paymentCmd.OrderID, paymentCmd.Amount, transactionID)
-	m.eventEmitter.EmitCtx(context.TODO(), event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
@@ -202,7 +199,9 @@ func (m *OrderModule) shipOrder(ctx context.Context, cmd gocmdevt.Command) (any,
 
 	// Emit event after successful shipping
 	event := NewOrderShippedEvent(shipCmd.OrderID, trackingNumber, shipCmd.ShippingAddress)
-	m.eventEmitter.EmitCtx(context.TODO(), event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
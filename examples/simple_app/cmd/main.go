@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	. "simple-app"
 
@@ -68,7 +69,11 @@ func main() {
 				fmt.Printf("[HANDLER] Order shipped: %s with address %s\n", shipEvent.OrderID, shipEvent.ShippingAddress)
 				return nil, nil
 			},
-		)
+		).WithRetry(gocmdevt.RetryPolicy{ // only prints, so it is safe to run again
+			MaxAttempts:    3,
+			InitialBackoff: 200 * time.Millisecond,
+			Jitter:         0.2,
+		})
 
 	}
 
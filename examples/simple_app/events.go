@@ -13,15 +13,7 @@ type OrderCreatedEvent struct {
 }
 
 func (e *OrderCreatedEvent) Payload() map[string]interface{} {
-	return map[string]interface{}{
-		"id":           e.ID,
-		"type":         e.Type,
-		"time":         e.Time,
-		"customer_id":  e.CustomerID,
-		"product_id":   e.ProductID,
-		"quantity":     e.Quantity,
-		"total_amount": e.TotalAmount,
-	}
+	return gocmdevt.PayloadOf(e)
 }
 
 func NewOrderCreatedEvent(orderID, customerID, productID string, quantity int, totalAmount float64) *OrderCreatedEvent {
@@ -41,13 +33,7 @@ type OrderShippedEvent struct {
 }
 
 func (e *OrderShippedEvent) Payload() map[string]interface{} {
-	return map[string]interface{}{
-		"id":               e.ID,
-		"type":             e.Type,
-		"time":             e.Time,
-		"order_id":         e.OrderID,
-		"shipping_address": e.ShippingAddress,
-	}
+	return gocmdevt.PayloadOf(e)
 }
 
 func NewOrderShippedEvent(orderID, shippingAddress string) *OrderShippedEvent {
@@ -66,14 +52,7 @@ type PaymentProcessedEvent struct {
 }
 
 func (e *PaymentProcessedEvent) Payload() map[string]interface{} {
-	return map[string]interface{}{
-		"id":             e.ID,
-		"type":           e.Type,
-		"time":           e.Time,
-		"order_id":       e.OrderID,
-		"amount":         e.Amount,
-		"transaction_id": e.TransactionID,
-	}
+	return gocmdevt.PayloadOf(e)
 }
 
 func NewPaymentProcessedEvent(orderID string, amount float64, transactionID string) *PaymentProcessedEvent {
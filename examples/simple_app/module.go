@@ -40,7 +40,9 @@ func (m *OrderModule) createOrder(ctx context.Context, cmd gocmdevt.Command) (an
 		createCmd.Quantity,
 		createCmd.TotalAmount,
 	)
-	m.eventEmitter.Emit(event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return createCmd, nil
 }
@@ -57,7 +59,9 @@ func (m *OrderModule) processPayment(ctx context.Context, cmd gocmdevt.Command)
 		processCmd.Amount,
 		processCmd.TransactionID,
 	)
-	m.eventEmitter.Emit(event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
@@ -73,7 +77,9 @@ func (m *OrderModule) shipOrder(ctx context.Context, cmd gocmdevt.Command) (any,
 		shipCmd.OrderID,
 		shipCmd.ShippingAddress,
 	)
-	m.eventEmitter.Emit(event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
@@ -0,0 +1,486 @@
+package gocmdevt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrStoreClosed is returned when using an event store after Close
+var ErrStoreClosed = errors.New("event store is closed")
+
+// SyncPolicy decides when a FileEventStore flushes appended records to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs before every Append returns
+	SyncAlways SyncPolicy = iota
+	// SyncPeriodically fsyncs in the background every SyncInterval
+	SyncPeriodically
+	// SyncNever leaves flushing to the operating system
+	SyncNever
+)
+
+// FileEventStoreConfig configures a FileEventStore
+type FileEventStoreConfig struct {
+	// Codec encodes events to JSON; defaults to JSONCodec
+	Codec EventCodec
+	// MaxSegmentSize is the size in bytes after which a new segment file is
+	// started; defaults to 64 MiB
+	MaxSegmentSize int64
+	// Sync decides when records are flushed to disk
+	Sync SyncPolicy
+	// SyncInterval is used with SyncPeriodically; defaults to one second
+	SyncInterval time.Duration
+}
+
+const (
+	segmentExt       = ".seg"
+	recordHeaderSize = 8 // uint32 payload length + uint32 CRC-32C of payload
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileEventStore is an EventStore kept in append-only segment files in a
+// directory. Each record is a length-prefixed, CRC-checked JSON document.
+// Segments are indexed in memory by position and AggregateID when opened.
+// A torn batch at the end of the last segment is truncated away, so each
+// Append is recovered whole or not at all.
+//
+// It also implements EventLogWriter, so it can back an EventEmitter directly.
+type FileEventStore struct {
+	dir            string
+	codec          EventCodec
+	maxSegmentSize int64
+	syncPolicy     SyncPolicy
+
+	mu          sync.RWMutex
+	segments    []*segment
+	records     []recordLoc      // by global position - 1
+	byAggregate map[string][]int // indexes into records
+	closed      bool
+
+	stopSync  chan struct{}
+	syncDone  chan struct{}
+	closeOnce sync.Once
+}
+
+type segment struct {
+	file *os.File
+	size int64
+}
+
+type recordLoc struct {
+	segment int
+	offset  int64
+	length  int64 // including header
+	version int
+}
+
+// fileRecord is the JSON document stored in each record
+type fileRecord struct {
+	Position    int64           `json:"position"`
+	Version     int             `json:"version"`
+	AggregateID string          `json:"aggregate_id"`
+	Event       json.RawMessage `json:"event"`
+	// Batch is the number of records the Append wrote, set on its first
+	// record only. Records from before batches were counted have none and
+	// stand alone.
+	Batch int `json:"batch,omitempty"`
+}
+
+// OpenFileEventStore opens or creates a store in dir.
+func OpenFileEventStore(dir string, config FileEventStoreConfig) (*FileEventStore, error) {
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+	if config.MaxSegmentSize <= 0 {
+		config.MaxSegmentSize = 64 << 20
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create event store dir: %w", err)
+	}
+
+	s := &FileEventStore{
+		dir:            dir,
+		codec:          config.Codec,
+		maxSegmentSize: config.MaxSegmentSize,
+		syncPolicy:     config.Sync,
+		byAggregate:    make(map[string][]int),
+	}
+	if err := s.recover(); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if s.syncPolicy == SyncPeriodically {
+		s.stopSync = make(chan struct{})
+		s.syncDone = make(chan struct{})
+		go s.syncLoop(config.SyncInterval)
+	}
+	return s, nil
+}
+
+// recover opens every segment and rebuilds the in-memory index
+func (s *FileEventStore) recover() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return s.createSegment(1)
+	}
+
+	for i, name := range names {
+		file, err := os.OpenFile(name, os.O_RDWR, 0o644)
+		if err != nil {
+			return fmt.Errorf("open segment: %w", err)
+		}
+		s.segments = append(s.segments, &segment{file: file})
+
+		last := i == len(names)-1
+		if err := s.scanSegment(i, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanSegment indexes the complete batches of a segment. A damaged tail,
+// from the start of its batch, is truncated in the last segment and
+// reported as an error in any other.
+func (s *FileEventStore) scanSegment(index int, last bool) error {
+	seg := s.segments[index]
+	info, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var offset, batchStart int64
+	var batch []recordLoc // records of the batch being read
+	var batchSize int
+	var batchAggregate string
+	var damage error
+	for offset < info.Size() {
+		rec, length, err := readRecord(seg.file, offset, info.Size())
+		if err == nil && rec.Position != int64(len(s.records)+len(batch)+1) {
+			err = fmt.Errorf("unexpected position %d", rec.Position)
+		}
+		if err == nil && len(batch) > 0 && (rec.Batch != 0 || rec.AggregateID != batchAggregate) {
+			err = fmt.Errorf("batch at offset %d ends after %d of %d records", batchStart, len(batch), batchSize)
+		}
+		if err != nil {
+			damage = fmt.Errorf("offset %d: %w", offset, err)
+			break
+		}
+
+		if len(batch) == 0 {
+			batchStart, batchSize, batchAggregate = offset, max(rec.Batch, 1), rec.AggregateID
+		}
+		batch = append(batch, recordLoc{segment: index, offset: offset, length: length, version: rec.Version})
+		offset += length
+
+		if len(batch) == batchSize {
+			for _, loc := range batch {
+				s.byAggregate[batchAggregate] = append(s.byAggregate[batchAggregate], len(s.records))
+				s.records = append(s.records, loc)
+			}
+			batch = batch[:0]
+		}
+	}
+	if damage == nil && len(batch) > 0 {
+		damage = fmt.Errorf("offset %d: batch has %d of %d records", batchStart, len(batch), batchSize)
+	}
+
+	if damage != nil {
+		if !last {
+			return fmt.Errorf("segment %s corrupt at %w", seg.file.Name(), damage)
+		}
+		// Appends are all or nothing, so the records of a torn batch go too
+		if len(batch) > 0 {
+			offset = batchStart
+		}
+		log.Printf("event store: truncating torn batch in %s at offset %d: %v", seg.file.Name(), offset, damage)
+		if err := seg.file.Truncate(offset); err != nil {
+			return fmt.Errorf("truncate segment: %w", err)
+		}
+	}
+	seg.size = offset
+	return nil
+}
+
+// readRecord reads and verifies the record at offset, which must end by
+// limit, and returns the record's total length
+func readRecord(r io.ReaderAt, offset, limit int64) (fileRecord, int64, error) {
+	var rec fileRecord
+	header := make([]byte, recordHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return rec, 0, fmt.Errorf("read record header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if offset+recordHeaderSize+int64(length) > limit {
+		return rec, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := r.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		return rec, 0, fmt.Errorf("read record payload: %w", err)
+	}
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return rec, 0, errors.New("record checksum mismatch")
+	}
+
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, 0, fmt.Errorf("decode record: %w", err)
+	}
+	return rec, recordHeaderSize + int64(length), nil
+}
+
+func (s *FileEventStore) createSegment(firstPosition int64) error {
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d%s", firstPosition, segmentExt))
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("create segment: %w", err)
+	}
+	s.segments = append(s.segments, &segment{file: file})
+	return nil
+}
+
+func (s *FileEventStore) Append(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrStoreClosed
+	}
+
+	current := len(s.byAggregate[aggregateID])
+	if err := checkAppend(aggregateID, expectedVersion, current, events); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return current, nil
+	}
+
+	// Encode the whole batch first so it is written with a single call
+	var buf []byte
+	locs := make([]recordLoc, 0, len(events))
+	for i, event := range events {
+		data, err := s.codec.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		rec := fileRecord{
+			Position:    int64(len(s.records) + i + 1),
+			Version:     current + i + 1,
+			AggregateID: aggregateID,
+			Event:       data,
+		}
+		if i == 0 {
+			rec.Batch = len(events)
+		}
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			return 0, fmt.Errorf("encode record: %w", err)
+		}
+
+		header := make([]byte, recordHeaderSize)
+		binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+		binary.BigEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+		locs = append(locs, recordLoc{
+			offset:  int64(len(buf)),
+			length:  int64(len(header) + len(payload)),
+			version: current + i + 1,
+		})
+		buf = append(append(buf, header...), payload...)
+	}
+
+	active := s.segments[len(s.segments)-1]
+	if active.size > 0 && active.size+int64(len(buf)) > s.maxSegmentSize {
+		if err := s.rotate(); err != nil {
+			return 0, err
+		}
+		active = s.segments[len(s.segments)-1]
+	}
+
+	if _, err := active.file.WriteAt(buf, active.size); err != nil {
+		// Drop whatever part of the batch made it to disk
+		active.file.Truncate(active.size)
+		return 0, fmt.Errorf("write records: %w", err)
+	}
+	if s.syncPolicy == SyncAlways {
+		if err := active.file.Sync(); err != nil {
+			// The batch was not appended, so it must not be recovered either
+			active.file.Truncate(active.size)
+			return 0, fmt.Errorf("sync segment: %w", err)
+		}
+	}
+
+	for _, loc := range locs {
+		loc.segment = len(s.segments) - 1
+		loc.offset += active.size
+		s.byAggregate[aggregateID] = append(s.byAggregate[aggregateID], len(s.records))
+		s.records = append(s.records, loc)
+	}
+	active.size += int64(len(buf))
+	return current + len(events), nil
+}
+
+// rotate flushes the active segment and starts a new one
+func (s *FileEventStore) rotate() error {
+	if err := s.segments[len(s.segments)-1].file.Sync(); err != nil {
+		return fmt.Errorf("sync segment: %w", err)
+	}
+	return s.createSegment(int64(len(s.records) + 1))
+}
+
+// Write appends event to its aggregate without a version check.
+func (s *FileEventStore) Write(event Event) error {
+	_, err := s.Append(context.Background(), event.AggregateID(), AnyVersion, event)
+	return err
+}
+
+func (s *FileEventStore) Load(ctx context.Context, aggregateID string, fromVersion int) ([]StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	indexes := s.byAggregate[aggregateID]
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	if fromVersion > len(indexes) {
+		return nil, nil
+	}
+
+	stored := make([]StoredEvent, 0, len(indexes)-fromVersion+1)
+	for _, i := range indexes[fromVersion-1:] {
+		event, err := s.read(i)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, event)
+	}
+	return stored, nil
+}
+
+func (s *FileEventStore) LoadAll(ctx context.Context, fromPosition int64, limit int) ([]StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	if fromPosition < 1 {
+		fromPosition = 1
+	}
+	end := int64(len(s.records))
+	if limit > 0 && fromPosition+int64(limit)-1 < end {
+		end = fromPosition + int64(limit) - 1
+	}
+
+	var stored []StoredEvent
+	for pos := fromPosition; pos <= end; pos++ {
+		event, err := s.read(int(pos - 1))
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, event)
+	}
+	return stored, nil
+}
+
+// read decodes the record at index; the caller must hold mu
+func (s *FileEventStore) read(index int) (StoredEvent, error) {
+	loc := s.records[index]
+	rec, _, err := readRecord(s.segments[loc.segment].file, loc.offset, loc.offset+loc.length)
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	event, err := s.codec.Unmarshal(rec.Event)
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	return StoredEvent{Event: event, Version: rec.Version, Position: rec.Position}, nil
+}
+
+func (s *FileEventStore) syncLoop(interval time.Duration) {
+	defer close(s.syncDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopSync:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			if err := s.segments[len(s.segments)-1].file.Sync(); err != nil {
+				log.Printf("event store: periodic sync failed: %v", err)
+			}
+			s.mu.RUnlock()
+		}
+	}
+}
+
+// Close flushes and closes the segment files.
+func (s *FileEventStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.stopSync != nil {
+			close(s.stopSync)
+			<-s.syncDone
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		if s.syncPolicy != SyncNever {
+			err = s.segments[len(s.segments)-1].file.Sync()
+		}
+		if closeErr := s.closeFiles(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (s *FileEventStore) closeFiles() error {
+	var errs []error
+	for _, seg := range s.segments {
+		errs = append(errs, seg.file.Close())
+	}
+	return errors.Join(errs...)
+}
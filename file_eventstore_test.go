@@ -0,0 +1,197 @@
+package gocmdevt_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gocmdevt "github.com/leviplj/go-cmd-evt"
+	"github.com/leviplj/go-cmd-evt/eventstoretest"
+)
+
+func openFileStore(t *testing.T, dir string, config gocmdevt.FileEventStoreConfig) *gocmdevt.FileEventStore {
+	t.Helper()
+	store, err := gocmdevt.OpenFileEventStore(dir, config)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestFileEventStore(t *testing.T) {
+	eventstoretest.Run(t, func(t *testing.T) gocmdevt.EventStore {
+		return openFileStore(t, t.TempDir(), gocmdevt.FileEventStoreConfig{})
+	})
+}
+
+func TestFileEventStore_Durability(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reopens with events and index intact", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		first := eventstoretest.NewNoteAdded("note-1", "a")
+		store.Append(ctx, "note-1", gocmdevt.NoStream, first)
+		store.Append(ctx, "note-2", gocmdevt.NoStream, eventstoretest.NewNoteAdded("note-2", "b"))
+		store.Close()
+
+		reopened := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		stored, err := reopened.Load(ctx, "note-1", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(stored) != 1 || stored[0].Event.EventID() != first.EventID() {
+			t.Fatalf("expected event %s, got %v", first.EventID(), stored)
+		}
+
+		recorded, ok := stored[0].Event.(*gocmdevt.RecordedEvent)
+		if !ok {
+			t.Fatalf("expected *RecordedEvent, got %T", stored[0].Event)
+		}
+
+		if recorded.Payload()["text"] != "a" {
+			t.Errorf("expected payload text 'a', got %v", recorded.Payload()["text"])
+		}
+
+		if _, err := reopened.Append(ctx, "note-1", 1, eventstoretest.NewNoteAdded("note-1", "c")); err != nil {
+			t.Errorf("expected append at version 1 after reopen, got %v", err)
+		}
+	})
+
+	t.Run("rotates segments", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{MaxSegmentSize: 512})
+		for i := 0; i < 10; i++ {
+			if _, err := store.Append(ctx, "note-1", i, eventstoretest.NewNoteAdded("note-1", "text")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		store.Close()
+
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		if len(segments) < 2 {
+			t.Fatalf("expected several segments, got %d", len(segments))
+		}
+
+		reopened := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{MaxSegmentSize: 512})
+		all, err := reopened.LoadAll(ctx, 1, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != 10 || all[9].Position != 10 || all[9].Version != 10 {
+			t.Errorf("expected 10 events ending at position 10, got %d", len(all))
+		}
+	})
+
+	t.Run("recovers from a torn final write", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		store.Append(ctx, "note-1", gocmdevt.NoStream, eventstoretest.NewNoteAdded("note-1", "a"))
+		store.Append(ctx, "note-1", 1, eventstoretest.NewNoteAdded("note-1", "b"))
+		store.Close()
+
+		// Cut the last record in half, as a crash mid-write would
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		last := segments[len(segments)-1]
+		info, _ := os.Stat(last)
+		if err := os.Truncate(last, info.Size()-10); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		stored, err := reopened.Load(ctx, "note-1", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(stored) != 1 {
+			t.Fatalf("expected 1 surviving event, got %d", len(stored))
+		}
+
+		if _, err := reopened.Append(ctx, "note-1", 1, eventstoretest.NewNoteAdded("note-1", "c")); err != nil {
+			t.Errorf("expected append after recovery, got %v", err)
+		}
+	})
+
+	t.Run("drops a torn batch whole", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		store.Append(ctx, "note-1", gocmdevt.NoStream, eventstoretest.NewNoteAdded("note-1", "a"))
+		store.Append(ctx, "note-1", 1,
+			eventstoretest.NewNoteAdded("note-1", "b"),
+			eventstoretest.NewNoteAdded("note-1", "c"),
+			eventstoretest.NewNoteAdded("note-1", "d"))
+		store.Close()
+
+		// Tear the last record of the batch
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		last := segments[len(segments)-1]
+		info, _ := os.Stat(last)
+		if err := os.Truncate(last, info.Size()-10); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		all, err := reopened.LoadAll(ctx, 1, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != 1 {
+			t.Fatalf("expected only the event before the batch, got %d", len(all))
+		}
+
+		if _, err := reopened.Append(ctx, "note-1", 1, eventstoretest.NewNoteAdded("note-1", "e")); err != nil {
+			t.Errorf("expected append at version 1 after recovery, got %v", err)
+		}
+	})
+
+	t.Run("drops a final record with a bad checksum", func(t *testing.T) {
+		dir := t.TempDir()
+		store := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		store.Append(ctx, "note-1", gocmdevt.NoStream, eventstoretest.NewNoteAdded("note-1", "a"))
+		store.Close()
+
+		segments, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		data, _ := os.ReadFile(segments[0])
+		data[len(data)-2] ^= 0xff
+		os.WriteFile(segments[0], data, 0o644)
+
+		reopened := openFileStore(t, dir, gocmdevt.FileEventStoreConfig{})
+		all, err := reopened.LoadAll(ctx, 1, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != 0 {
+			t.Errorf("expected corrupt record to be dropped, got %d events", len(all))
+		}
+	})
+
+	t.Run("backs an event emitter", func(t *testing.T) {
+		store := openFileStore(t, t.TempDir(), gocmdevt.FileEventStoreConfig{Sync: gocmdevt.SyncNever})
+		emitter := gocmdevt.NewEventEmitter(store, gocmdevt.NewInMemoryDispatcher())
+
+		if err := emitter.Emit(eventstoretest.NewNoteAdded("note-1", "a")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored, _ := store.Load(ctx, "note-1", 1)
+		if len(stored) != 1 {
+			t.Errorf("expected 1 stored event, got %d", len(stored))
+		}
+	})
+
+	t.Run("rejects use after close", func(t *testing.T) {
+		store := openFileStore(t, t.TempDir(), gocmdevt.FileEventStoreConfig{Sync: gocmdevt.SyncPeriodically})
+		store.Close()
+
+		if err := store.Write(eventstoretest.NewNoteAdded("note-1", "a")); err != gocmdevt.ErrStoreClosed {
+			t.Errorf("expected ErrStoreClosed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package gocmdevt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+)
+
+// IDGenerator creates unique identifiers for events and commands
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function into an IDGenerator, for example to get
+// deterministic IDs in tests.
+type IDGeneratorFunc func() string
+
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+var defaultIDGenerator atomic.Pointer[IDGenerator]
+
+func init() {
+	SetDefaultIDGenerator(&UUIDv7Generator{})
+}
+
+// SetDefaultIDGenerator sets the generator used by NewBaseEvent, BaseCommand
+// and App.Handle when none is given. The default is a UUIDv7Generator.
+func SetDefaultIDGenerator(g IDGenerator) {
+	defaultIDGenerator.Store(&g)
+}
+
+// DefaultIDGenerator returns the package's default IDGenerator
+func DefaultIDGenerator() IDGenerator {
+	return *defaultIDGenerator.Load()
+}
+
+// generateEventID creates a unique identifier with the default generator
+func generateEventID() string {
+	return DefaultIDGenerator().NewID()
+}
+
+// UUIDv4Generator creates random RFC 9562 version 4 UUIDs
+type UUIDv4Generator struct{}
+
+func (UUIDv4Generator) NewID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 9562 variant
+	return formatUUID(id)
+}
+
+// UUIDv7Generator creates RFC 9562 version 7 UUIDs, which sort by creation
+// time. IDs from one generator are strictly increasing: within a millisecond
+// the 12 bits after the timestamp count up from a random start.
+type UUIDv7Generator struct {
+	// Clock provides the timestamps, DefaultClock when nil
+	Clock Clock
+
+	mu      sync.Mutex
+	lastMs  int64
+	counter uint16
+}
+
+func (g *UUIDv7Generator) NewID() string {
+	var id [16]byte
+	rand.Read(id[6:])
+
+	g.mu.Lock()
+	ms := clockOrDefault(g.Clock).Now().UnixMilli()
+	if ms <= g.lastMs {
+		g.counter++
+		if g.counter > 0x0fff {
+			// Counter exhausted: borrow the next millisecond
+			g.lastMs++
+			g.counter = 0
+		}
+		ms = g.lastMs
+	} else {
+		g.lastMs = ms
+		// Start in the lower half to leave room for increments
+		g.counter = binary.BigEndian.Uint16(id[6:8]) & 0x07ff
+	}
+	counter := g.counter
+	g.mu.Unlock()
+
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	id[6] = 0x70 | byte(counter>>8) // version 7
+	id[7] = byte(counter)
+	id[8] = id[8]&0x3f | 0x80 // RFC 9562 variant
+	return formatUUID(id)
+}
+
+func formatUUID(id [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf[:])
+}
+
+// crockford is the ULID alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator creates ULIDs: 26 characters of Crockford base32 that sort
+// by creation time. IDs from one generator are strictly increasing: within
+// a millisecond the random part is incremented.
+type ULIDGenerator struct {
+	// Clock provides the timestamps, DefaultClock when nil
+	Clock Clock
+
+	mu      sync.Mutex
+	lastMs  int64
+	entropy [10]byte
+}
+
+func (g *ULIDGenerator) NewID() string {
+	var id [16]byte
+
+	g.mu.Lock()
+	ms := clockOrDefault(g.Clock).Now().UnixMilli()
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		if !increment(g.entropy[:]) {
+			// Random part exhausted: borrow the next millisecond
+			g.lastMs++
+			ms = g.lastMs
+			rand.Read(g.entropy[:])
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.entropy[:])
+	}
+	copy(id[6:], g.entropy[:])
+	g.mu.Unlock()
+
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	return encodeULID(id)
+}
+
+// increment adds one to the big-endian number b, reporting false on overflow
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID writes the 128 bits of id as 26 base32 digits, most
+// significant first; the leading digit holds only 3 bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
@@ -0,0 +1,111 @@
+package gocmdevt
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+var (
+	uuidv4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	uuidv7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestIDGenerators(t *testing.T) {
+	generators := []struct {
+		name    string
+		gen     IDGenerator
+		pattern *regexp.Regexp
+		ordered bool
+	}{
+		{"UUIDv4", UUIDv4Generator{}, uuidv4Pattern, false},
+		{"UUIDv7", &UUIDv7Generator{}, uuidv7Pattern, true},
+		{"ULID", &ULIDGenerator{}, ulidPattern, true},
+	}
+
+	for _, g := range generators {
+		t.Run(g.name, func(t *testing.T) {
+			ids := make([]string, 10000)
+			seen := make(map[string]bool, len(ids))
+			for i := range ids {
+				ids[i] = g.gen.NewID()
+				if !g.pattern.MatchString(ids[i]) {
+					t.Fatalf("expected %s format, got %q", g.name, ids[i])
+				}
+				if seen[ids[i]] {
+					t.Fatalf("expected unique IDs, got %q twice", ids[i])
+				}
+				seen[ids[i]] = true
+			}
+
+			if g.ordered && !sort.StringsAreSorted(ids) {
+				t.Error("expected IDs to sort in generation order")
+			}
+		})
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	if got := encodeULID([16]byte{}); got != "00000000000000000000000000" {
+		t.Errorf("expected all zeros, got %s", got)
+	}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("expected 7ZZZZZZZZZZZZZZZZZZZZZZZZZ, got %s", got)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	b := []byte{0x00, 0xff}
+	if !increment(b) || b[0] != 0x01 || b[1] != 0x00 {
+		t.Errorf("expected carry into [1 0], got %v", b)
+	}
+
+	b = []byte{0xff, 0xff}
+	if increment(b) {
+		t.Error("expected overflow, got none")
+	}
+}
+
+func TestNewBaseEvent_IDGenerator(t *testing.T) {
+	sequence := func() IDGenerator {
+		n := 0
+		return IDGeneratorFunc(func() string {
+			n++
+			return fmt.Sprintf("id-%d", n)
+		})
+	}
+
+	t.Run("uses an injected generator", func(t *testing.T) {
+		ids := sequence()
+
+		first := NewBaseEvent("UserCreated", "user-1", 1, WithIDGenerator(ids))
+		second := NewBaseEvent("UserCreated", "user-1", 1, WithIDGenerator(ids))
+
+		if first.ID != "id-1" || second.ID != "id-2" {
+			t.Errorf("expected id-1 and id-2, got %s and %s", first.ID, second.ID)
+		}
+	})
+
+	t.Run("uses the package default", func(t *testing.T) {
+		previous := DefaultIDGenerator()
+		defer SetDefaultIDGenerator(previous)
+		SetDefaultIDGenerator(sequence())
+
+		if event := NewUserCreatedEvent("user-1", "John"); event.EventID() != "id-1" {
+			t.Errorf("expected id-1, got %s", event.EventID())
+		}
+	})
+
+	t.Run("defaults to UUIDv7", func(t *testing.T) {
+		if id := NewBaseEvent("UserCreated", "user-1", 1).ID; !uuidv7Pattern.MatchString(id) {
+			t.Errorf("expected a UUIDv7, got %q", id)
+		}
+	})
+}
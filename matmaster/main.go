@@ -30,23 +30,20 @@ func (d *InMemoryDispatcher) Subscribe(event gocmdevt.Event, handler gocmdevt.Ev
 	d.handlers[eventType] = append(d.handlers[eventType], handler)
 }
 
-func (d *InMemoryDispatcher) Dispatch(event gocmdevt.Event) {
-	ctx := context.Background()
-	eventType := reflect.TypeOf(event)
-	if handlers, exists := d.handlers[eventType]; exists {
-		for _, handler := range handlers {
-			handler(ctx, event)
-		}
-	}
+func (d *InMemoryDispatcher) Dispatch(event gocmdevt.Event) error {
+	return d.DispatchCtx(context.Background(), event)
 }
 
-func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event gocmdevt.Event) {
+func (d *InMemoryDispatcher) DispatchCtx(ctx context.Context, event gocmdevt.Event) error {
 	eventType := reflect.TypeOf(event)
 	if handlers, exists := d.handlers[eventType]; exists {
 		for _, handler := range handlers {
-			handler(ctx, event)
+			if _, err := handler(ctx, event); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 type StudentModule struct {
@@ -75,7 +72,9 @@ func (m *StudentModule) createStudent(ctx context.Context, cmd gocmdevt.Command)
 
 	// Emit event
 	event := gocmdevt.NewBaseEvent("student_created", createCmd.ID, 1)
-	m.eventEmitter.EmitCtx(ctx, event)
+	if err := m.eventEmitter.EmitCtx(ctx, event); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
@@ -0,0 +1,57 @@
+package gocmdevt
+
+import "reflect"
+
+// Middleware wraps a HandlerFunc to add behavior around command handling.
+// A middleware may enrich the context, short-circuit by not calling next,
+// or inspect and replace the result and error returned by next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// MiddlewareProvider is an optional interface for modules that want their
+// handlers wrapped with module-level middleware when registered.
+type MiddlewareProvider interface {
+	Middleware() []Middleware
+}
+
+// Chain composes middlewares into one. The first middleware is the outermost.
+func Chain(mws ...Middleware) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Use appends global middleware that wraps every command handled by the app.
+func (a *App) Use(mws ...Middleware) {
+	a.update(func(s *appState) error {
+		s.middlewares = append(s.middlewares, mws...)
+		return nil
+	})
+}
+
+// UseFor appends middleware that only wraps commands of the same type as cmd.
+// It runs inside global middleware and outside module middleware.
+func (a *App) UseFor(cmd Command, mws ...Middleware) {
+	typ := reflect.TypeOf(cmd)
+	a.update(func(s *appState) error {
+		s.typeMiddlewares[typ] = append(s.typeMiddlewares[typ], mws...)
+		return nil
+	})
+}
+
+// moduleHandlers returns the module's handlers wrapped with its own middleware
+func moduleHandlers(module Module) map[reflect.Type]HandlerFunc {
+	handlers := module.Handlers()
+	provider, ok := module.(MiddlewareProvider)
+	if !ok {
+		return handlers
+	}
+	chain := Chain(provider.Middleware()...)
+	wrapped := make(map[reflect.Type]HandlerFunc, len(handlers))
+	for typ, h := range handlers {
+		wrapped[typ] = chain(h)
+	}
+	return wrapped
+}
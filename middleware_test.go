@@ -0,0 +1,175 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordingMiddleware appends name to calls before and after calling next
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (any, error) {
+			*calls = append(*calls, name+":before")
+			result, err := next(ctx, cmd)
+			*calls = append(*calls, name+":after")
+			return result, err
+		}
+	}
+}
+
+type middlewareModule struct {
+	*UserModule
+	mws []Middleware
+}
+
+func (m *middlewareModule) Middleware() []Middleware {
+	return m.mws
+}
+
+func TestApp_Use(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs global middleware in registration order", func(t *testing.T) {
+		var calls []string
+		app := NewApp(NewUserModule())
+		app.Use(recordingMiddleware("first", &calls), recordingMiddleware("second", &calls))
+
+		if _, err := app.Handle(ctx, &CreateUserCommand{Name: "John"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{"first:before", "second:before", "second:after", "first:after"}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("orders global, command and module middleware", func(t *testing.T) {
+		var calls []string
+		module := &middlewareModule{
+			UserModule: NewUserModule(),
+			mws:        []Middleware{recordingMiddleware("module", &calls)},
+		}
+		app := NewApp(module)
+		app.UseFor(&CreateUserCommand{}, recordingMiddleware("command", &calls))
+		app.Use(recordingMiddleware("global", &calls))
+
+		if _, err := app.Handle(ctx, &CreateUserCommand{Name: "John"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []string{
+			"global:before", "command:before", "module:before",
+			"module:after", "command:after", "global:after",
+		}
+		if !reflect.DeepEqual(calls, expected) {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("command middleware only wraps its command type", func(t *testing.T) {
+		var calls []string
+		app := NewApp(NewUserModule())
+		app.UseFor(&CreateUserCommand{}, recordingMiddleware("command", &calls))
+
+		if _, err := app.Handle(ctx, &DeleteUserCommand{ID: 1}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(calls) != 0 {
+			t.Errorf("expected no middleware calls, got %v", calls)
+		}
+	})
+
+	t.Run("short-circuits without calling handler", func(t *testing.T) {
+		errDenied := errors.New("denied")
+		called := false
+		app := NewApp()
+		Register(app, func(ctx context.Context, cmd *CreateUserCommand) (any, error) {
+			called = true
+			return nil, nil
+		})
+		app.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, cmd Command) (any, error) {
+				return nil, errDenied
+			}
+		})
+
+		_, err := app.Handle(ctx, &CreateUserCommand{})
+		if !errors.Is(err, errDenied) {
+			t.Errorf("expected %v, got %v", errDenied, err)
+		}
+
+		if called {
+			t.Error("expected handler not to be called")
+		}
+	})
+
+	t.Run("enriches context for handler", func(t *testing.T) {
+		app := NewApp()
+		Register(app, func(ctx context.Context, cmd *CreateUserCommand) (any, error) {
+			return ctx.Value(testContextKey), nil
+		})
+		app.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, cmd Command) (any, error) {
+				return next(context.WithValue(ctx, testContextKey, "enriched"), cmd)
+			}
+		})
+
+		result, err := app.Handle(ctx, &CreateUserCommand{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result != "enriched" {
+			t.Errorf("expected 'enriched', got %v", result)
+		}
+	})
+
+	t.Run("inspects and replaces results and errors", func(t *testing.T) {
+		errHandler := errors.New("handler failed")
+		var seen error
+		app := NewApp()
+		Register(app, func(ctx context.Context, cmd *CreateUserCommand) (any, error) {
+			return nil, errHandler
+		})
+		app.Use(func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, cmd Command) (any, error) {
+				result, err := next(ctx, cmd)
+				seen = err
+				if err != nil {
+					return "recovered", nil
+				}
+				return result, nil
+			}
+		})
+
+		result, err := app.Handle(ctx, &CreateUserCommand{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !errors.Is(seen, errHandler) {
+			t.Errorf("expected middleware to see %v, got %v", errHandler, seen)
+		}
+
+		if result != "recovered" {
+			t.Errorf("expected 'recovered', got %v", result)
+		}
+	})
+}
+
+func TestChain(t *testing.T) {
+	t.Run("empty chain returns handler unchanged", func(t *testing.T) {
+		h := Chain()(func(ctx context.Context, cmd Command) (any, error) {
+			return "ok", nil
+		})
+
+		result, err := h(context.Background(), nil)
+		if err != nil || result != "ok" {
+			t.Errorf("expected ok, got %v, %v", result, err)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OutboxEntry is an event staged for delivery
+type OutboxEntry struct {
+	ID       int64
+	Event    Event
+	StagedAt time.Time
+	// Attempts counts failed deliveries
+	Attempts  int
+	LastError string
+}
+
+// Outbox keeps emitted events until an OutboxRelay has delivered them.
+// Staging events together with the state change that caused them, in one
+// transaction, means a crash can neither lose events nor deliver events of
+// a change that was rolled back.
+type Outbox interface {
+	// Stage records events for delivery. Database-backed outboxes stage them
+	// in the transaction carried by ctx, see ContextWithTx.
+	Stage(ctx context.Context, events ...Event) error
+
+	// Pending returns up to limit entries that are neither delivered nor
+	// abandoned, oldest first.
+	Pending(ctx context.Context, limit int) ([]OutboxEntry, error)
+
+	// MarkDelivered records that the entry was delivered.
+	MarkDelivered(ctx context.Context, id int64) error
+
+	// MarkFailed records a failed delivery attempt. Abandoned entries are no
+	// longer returned by Pending.
+	MarkFailed(ctx context.Context, id int64, cause error, abandon bool) error
+}
+
+// InMemoryOutbox is an Outbox for tests and single-process setups. Staging
+// takes effect immediately; it cannot join a database transaction.
+type InMemoryOutbox struct {
+	mu      sync.Mutex
+	entries []*memoryOutboxEntry
+}
+
+type memoryOutboxEntry struct {
+	OutboxEntry
+	delivered bool
+	abandoned bool
+}
+
+func NewInMemoryOutbox() *InMemoryOutbox {
+	return &InMemoryOutbox{}
+}
+
+func (o *InMemoryOutbox) Stage(ctx context.Context, events ...Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := DefaultClock().Now().UTC()
+	for _, event := range events {
+		o.entries = append(o.entries, &memoryOutboxEntry{OutboxEntry: OutboxEntry{
+			ID:       int64(len(o.entries) + 1),
+			Event:    event,
+			StagedAt: now,
+		}})
+	}
+	return nil
+}
+
+func (o *InMemoryOutbox) Pending(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var pending []OutboxEntry
+	for _, e := range o.entries {
+		if limit > 0 && len(pending) >= limit {
+			break
+		}
+		if !e.delivered && !e.abandoned {
+			pending = append(pending, e.OutboxEntry)
+		}
+	}
+	return pending, nil
+}
+
+func (o *InMemoryOutbox) MarkDelivered(ctx context.Context, id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, err := o.entry(id)
+	if err != nil {
+		return err
+	}
+	entry.delivered = true
+	return nil
+}
+
+func (o *InMemoryOutbox) MarkFailed(ctx context.Context, id int64, cause error, abandon bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry, err := o.entry(id)
+	if err != nil {
+		return err
+	}
+	entry.Attempts++
+	entry.LastError = cause.Error()
+	entry.abandoned = abandon
+	return nil
+}
+
+// entry returns the entry with id; the caller holds mu
+func (o *InMemoryOutbox) entry(id int64) (*memoryOutboxEntry, error) {
+	if id < 1 || id > int64(len(o.entries)) {
+		return nil, fmt.Errorf("outbox entry %d not found", id)
+	}
+	return o.entries[id-1], nil
+}
+
+// ###
+
+// ErrRelayStopped is returned when starting an OutboxRelay after Shutdown
+var ErrRelayStopped = errors.New("outbox relay is stopped")
+
+// OutboxRelayConfig configures an OutboxRelay
+type OutboxRelayConfig struct {
+	// BatchSize is the number of entries read per pass; defaults to 100
+	BatchSize int
+	// PollInterval is the pause between passes that found nothing to
+	// deliver; defaults to one second
+	PollInterval time.Duration
+	// MaxAttempts abandons entries after that many failed deliveries;
+	// zero retries forever
+	MaxAttempts int
+	// ErrorHook receives delivery failures and outbox errors
+	ErrorHook ErrorHook
+	// Clock paces the polling; defaults to DefaultClock
+	Clock Clock
+}
+
+// OutboxRelay delivers staged events from an Outbox to a Dispatcher, at
+// least once and in staging order. The Dispatcher is the delivery target:
+// in-process handlers, or an external publisher through DispatcherFunc. An
+// entry is marked delivered only after the dispatcher accepted it, so a
+// crash in between delivers it again and handlers should be idempotent.
+// When delivery fails, later entries of the same aggregate wait for the
+// next pass.
+type OutboxRelay struct {
+	outbox     Outbox
+	dispatcher Dispatcher
+	config     OutboxRelayConfig
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewOutboxRelay(outbox Outbox, dispatcher Dispatcher, config OutboxRelayConfig) *OutboxRelay {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	config.Clock = clockOrDefault(config.Clock)
+
+	return &OutboxRelay{
+		outbox:     outbox,
+		dispatcher: dispatcher,
+		config:     config,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start runs the relay in a goroutine until Shutdown
+func (r *OutboxRelay) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return ErrRelayStopped
+	}
+	if r.started {
+		return nil
+	}
+	r.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.run(ctx)
+	return nil
+}
+
+func (r *OutboxRelay) run(ctx context.Context) {
+	defer close(r.done)
+	for {
+		delivered, err := r.RelayPending(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			reportError(r.config.ErrorHook, ctx, nil, err)
+		}
+		// A full batch suggests more is waiting
+		if delivered >= r.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.config.Clock.After(r.config.PollInterval):
+		}
+	}
+}
+
+// RelayPending makes one delivery pass over up to BatchSize pending entries
+// and returns how many were delivered.
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	entries, err := r.outbox.Pending(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("read outbox: %w", err)
+	}
+
+	// Entries are delivered and marked without ctx's cancellation, so
+	// stopping between the two does not redeliver needlessly
+	deliverCtx := context.WithoutCancel(ctx)
+
+	delivered := 0
+	blocked := map[string]bool{} // aggregates with a failed entry this pass
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+		aggregateID := entry.Event.AggregateID()
+		if aggregateID != "" && blocked[aggregateID] {
+			continue
+		}
+
+		if err := r.dispatcher.DispatchCtx(deliverCtx, entry.Event); err != nil {
+			if aggregateID != "" {
+				blocked[aggregateID] = true
+			}
+			r.fail(deliverCtx, entry, err)
+			continue
+		}
+		if err := r.outbox.MarkDelivered(deliverCtx, entry.ID); err != nil {
+			return delivered, fmt.Errorf("mark outbox entry %d delivered: %w", entry.ID, err)
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// fail records a failed delivery, abandoning the entry after MaxAttempts
+func (r *OutboxRelay) fail(ctx context.Context, entry OutboxEntry, cause error) {
+	abandon := r.config.MaxAttempts > 0 && entry.Attempts+1 >= r.config.MaxAttempts
+	if abandon {
+		cause = fmt.Errorf("outbox entry %d abandoned after %d attempts: %w", entry.ID, entry.Attempts+1, cause)
+	}
+	reportError(r.config.ErrorHook, ctx, entry.Event, cause)
+
+	if err := r.outbox.MarkFailed(ctx, entry.ID, cause, abandon); err != nil {
+		reportError(r.config.ErrorHook, ctx, entry.Event, fmt.Errorf("mark outbox entry %d failed: %w", entry.ID, err))
+	}
+}
+
+// Shutdown stops the relay after its current delivery, waiting until it has
+// stopped or ctx is done.
+func (r *OutboxRelay) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopped = true
+	started := r.started
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,226 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leviplj/go-cmd-evt/clocktest"
+)
+
+// newOutboxEmitter returns an emitter staging into an in-memory outbox and
+// a relay delivering to dispatcher
+func newOutboxEmitter(dispatcher Dispatcher, config OutboxRelayConfig) (*EventEmitter, *InMemoryOutbox, *OutboxRelay) {
+	outbox := NewInMemoryOutbox()
+	emitter := NewEventEmitter(&recordingLogWriter{}, dispatcher)
+	emitter.Outbox = outbox
+	return emitter, outbox, NewOutboxRelay(outbox, dispatcher, config)
+}
+
+func TestEventEmitter_Outbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stages events instead of dispatching", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		var dispatched int
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			dispatched++
+			return nil, nil
+		})
+		emitter, outbox, _ := newOutboxEmitter(dispatcher, OutboxRelayConfig{})
+		logWriter := emitter.LogWriter.(*recordingLogWriter)
+
+		if err := emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := emitter.EmitVersioned(ctx, "user-2", AnyVersion, NewUserCreatedEvent("user-2", "Jane")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if dispatched != 0 {
+			t.Errorf("expected no dispatch, got %d", dispatched)
+		}
+		if len(logWriter.events) != 2 {
+			t.Errorf("expected 2 logged events, got %d", len(logWriter.events))
+		}
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 2 {
+			t.Errorf("expected 2 staged events, got %d", len(pending))
+		}
+	})
+
+	t.Run("does not stage events that failed to log", func(t *testing.T) {
+		emitter, outbox, _ := newOutboxEmitter(NewInMemoryDispatcher(), OutboxRelayConfig{})
+		emitter.LogWriter = &recordingLogWriter{err: errors.New("disk full")}
+
+		if err := emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John")); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Errorf("expected nothing staged, got %d", len(pending))
+		}
+	})
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivers staged events once", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		var names []string
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			names = append(names, e.(*UserCreatedEvent).Name)
+			return nil, nil
+		})
+		emitter, _, relay := newOutboxEmitter(dispatcher, OutboxRelayConfig{})
+
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-2", "Jane"))
+
+		if n, err := relay.RelayPending(ctx); err != nil || n != 2 {
+			t.Fatalf("expected 2 delivered, got %d, %v", n, err)
+		}
+		if n, _ := relay.RelayPending(ctx); n != 0 {
+			t.Errorf("expected nothing left to deliver, got %d", n)
+		}
+		if len(names) != 2 || names[0] != "John" || names[1] != "Jane" {
+			t.Errorf("expected John then Jane, got %v", names)
+		}
+	})
+
+	t.Run("retries failures and holds back their aggregate", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		failing := true
+		var delivered []string
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			if failing && e.AggregateID() == "user-1" {
+				return nil, errors.New("handler down")
+			}
+			delivered = append(delivered, e.EventType()+":"+e.AggregateID())
+			return nil, nil
+		})
+		var hooked []error
+		emitter, outbox, relay := newOutboxEmitter(dispatcher, OutboxRelayConfig{
+			ErrorHook: func(ctx context.Context, event Event, err error) { hooked = append(hooked, err) },
+		})
+
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+		emitter.EmitCtx(ctx, NewUserDeletedEvent("user-1"))
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-2", "Jane"))
+
+		if n, _ := relay.RelayPending(ctx); n != 1 {
+			t.Fatalf("expected only user-2 delivered, got %d", n)
+		}
+		if len(hooked) != 1 {
+			t.Errorf("expected 1 reported failure, got %d", len(hooked))
+		}
+		pending, _ := outbox.Pending(ctx, 0)
+		if len(pending) != 2 || pending[0].Attempts != 1 || pending[1].Attempts != 0 {
+			t.Fatalf("expected the failed and the held back entry, got %+v", pending)
+		}
+
+		failing = false
+		relay.RelayPending(ctx)
+
+		expected := []string{"UserCreated:user-2", "UserCreated:user-1", "UserDeleted:user-1"}
+		if len(delivered) != 3 || delivered[1] != expected[1] || delivered[2] != expected[2] {
+			t.Errorf("expected %v, got %v", expected, delivered)
+		}
+	})
+
+	t.Run("delivers to any target through DispatcherFunc", func(t *testing.T) {
+		var sent []string
+		target := DispatcherFunc(func(ctx context.Context, e Event) error {
+			sent = append(sent, e.EventID())
+			return nil
+		})
+		emitter, _, relay := newOutboxEmitter(target, OutboxRelayConfig{})
+		event := NewUserCreatedEvent("user-1", "John")
+		emitter.EmitCtx(ctx, event)
+
+		if n, err := relay.RelayPending(ctx); err != nil || n != 1 {
+			t.Fatalf("expected 1 delivered, got %d, %v", n, err)
+		}
+		if len(sent) != 1 || sent[0] != event.EventID() {
+			t.Errorf("expected %s to be sent, got %v", event.EventID(), sent)
+		}
+	})
+
+	t.Run("abandons entries after MaxAttempts", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			return nil, errors.New("poison")
+		})
+		var hooked []error
+		emitter, outbox, relay := newOutboxEmitter(dispatcher, OutboxRelayConfig{
+			MaxAttempts: 2,
+			ErrorHook:   func(ctx context.Context, event Event, err error) { hooked = append(hooked, err) },
+		})
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+
+		relay.RelayPending(ctx)
+		relay.RelayPending(ctx)
+
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Errorf("expected abandoned entry to leave pending, got %d", len(pending))
+		}
+		if len(hooked) != 2 {
+			t.Errorf("expected 2 reported failures, got %d", len(hooked))
+		}
+	})
+
+	t.Run("polls in the background until shut down", func(t *testing.T) {
+		dispatcher := NewInMemoryDispatcher()
+		delivered := make(chan string, 2)
+		dispatcher.Subscribe(&UserCreatedEvent{}, func(ctx context.Context, e Event) (any, error) {
+			delivered <- e.(*UserCreatedEvent).Name
+			return nil, nil
+		})
+		clock := clocktest.New(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+		emitter, _, relay := newOutboxEmitter(dispatcher, OutboxRelayConfig{PollInterval: time.Second, Clock: clock})
+
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+		if err := relay.Start(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := <-delivered; got != "John" {
+			t.Errorf("expected John, got %s", got)
+		}
+
+		// The relay waits for the poll interval before looking again
+		clock.BlockUntilWaiters(1)
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-2", "Jane"))
+		clock.Advance(time.Second)
+		if got := <-delivered; got != "Jane" {
+			t.Errorf("expected Jane, got %s", got)
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if err := relay.Shutdown(shutdownCtx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := relay.Start(); !errors.Is(err, ErrRelayStopped) {
+			t.Errorf("expected ErrRelayStopped, got %v", err)
+		}
+	})
+}
+
+func TestInMemoryOutbox(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewInMemoryOutbox()
+	outbox.Stage(ctx, NewUserCreatedEvent("user-1", "John"), NewUserCreatedEvent("user-2", "Jane"))
+
+	t.Run("limits pending entries", func(t *testing.T) {
+		pending, _ := outbox.Pending(ctx, 1)
+		if len(pending) != 1 || pending[0].ID != 1 {
+			t.Errorf("expected entry 1, got %+v", pending)
+		}
+	})
+
+	t.Run("rejects unknown entries", func(t *testing.T) {
+		if err := outbox.MarkDelivered(ctx, 3); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
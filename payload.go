@@ -0,0 +1,263 @@
+package gocmdevt
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// PayloadOf returns the fields of the struct v, or of the struct v points
+// to, keyed by their JSON names. It follows the encoding/json rules for
+// tags, omitempty, omitzero and embedded structs. Nested structs become
+// nested maps, except for types such as time.Time that marshal themselves.
+//
+// PayloadOf(event) returns the full payload of any event, whether or not
+// its type implements Payload. Events embedding BaseEvent can implement
+// Payload with it:
+//
+//	func (e *OrderCreatedEvent) Payload() map[string]interface{} {
+//		return gocmdevt.PayloadOf(e)
+//	}
+func PayloadOf(v any) map[string]interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return planFor(rv.Type()).payload(rv)
+}
+
+// payloadPlan lists the fields of a struct type that end up in its payload
+type payloadPlan struct {
+	fields []payloadField
+}
+
+type payloadField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+	// nested is set for struct fields that become nested maps
+	nested *payloadPlan
+}
+
+var (
+	// payloadPlans holds complete plans, readable without locking
+	payloadPlans   sync.Map // reflect.Type -> *payloadPlan
+	payloadPlansMu sync.Mutex
+)
+
+// planFor returns the cached plan for the struct type t, building it and
+// the plans of its nested structs on first use
+func planFor(t reflect.Type) *payloadPlan {
+	if plan, ok := payloadPlans.Load(t); ok {
+		return plan.(*payloadPlan)
+	}
+
+	payloadPlansMu.Lock()
+	defer payloadPlansMu.Unlock()
+
+	building := map[reflect.Type]*payloadPlan{}
+	plan := buildPlan(t, building)
+	for typ, p := range building {
+		payloadPlans.Store(typ, p)
+	}
+	return plan
+}
+
+// buildPlan returns the plan for t, registering it in building before
+// filling it so recursive types resolve to it
+func buildPlan(t reflect.Type, building map[reflect.Type]*payloadPlan) *payloadPlan {
+	if plan, ok := payloadPlans.Load(t); ok {
+		return plan.(*payloadPlan)
+	}
+	if plan, ok := building[t]; ok {
+		return plan
+	}
+
+	plan := &payloadPlan{}
+	building[t] = plan
+	plan.fields = collectFields(t, building)
+	return plan
+}
+
+func (p *payloadPlan) payload(v reflect.Value) map[string]interface{} {
+	payload := make(map[string]interface{}, len(p.fields))
+	for _, f := range p.fields {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// Behind a nil embedded pointer
+			continue
+		}
+		if (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && fv.IsZero()) {
+			continue
+		}
+		payload[f.name] = f.value(fv)
+	}
+	return payload
+}
+
+func (f payloadField) value(v reflect.Value) interface{} {
+	if f.nested == nil {
+		return v.Interface()
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return f.nested.payload(v)
+}
+
+// candidate is a field found while walking embedded structs
+type candidate struct {
+	payloadField
+	depth  int
+	tagged bool
+}
+
+// collectFields applies the encoding/json field rules: embedded structs
+// without a name are flattened, and among fields sharing a name the
+// shallowest wins, or the tagged one at equal depth
+func collectFields(t reflect.Type, building map[reflect.Type]*payloadPlan) []payloadField {
+	var found []candidate
+	walkFields(t, nil, 0, map[reflect.Type]bool{}, building, &found)
+
+	byName := map[string][]candidate{}
+	var order []string
+	for _, c := range found {
+		if _, ok := byName[c.name]; !ok {
+			order = append(order, c.name)
+		}
+		byName[c.name] = append(byName[c.name], c)
+	}
+
+	var fields []payloadField
+	for _, name := range order {
+		if winner, ok := dominant(byName[name]); ok {
+			fields = append(fields, winner.payloadField)
+		}
+	}
+	return fields
+}
+
+func walkFields(t reflect.Type, index []int, depth int, visited map[reflect.Type]bool, building map[reflect.Type]*payloadPlan, found *[]candidate) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// Embedded structs contribute their fields, even when unexported
+			walkFields(ft, fieldIndex, depth+1, visited, building, found)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		field := payloadField{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: hasOption(opts, "omitempty"),
+			omitZero:  hasOption(opts, "omitzero"),
+		}
+		if field.name == "" {
+			field.name = sf.Name
+		}
+		if ft.Kind() == reflect.Struct && !marshalsItself(ft) {
+			field.nested = buildPlan(ft, building)
+		}
+		*found = append(*found, candidate{field, depth, name != ""})
+	}
+}
+
+// dominant picks the field that wins a name, if any
+func dominant(candidates []candidate) (candidate, bool) {
+	depth := candidates[0].depth
+	for _, c := range candidates {
+		depth = min(depth, c.depth)
+	}
+
+	var shallowest []candidate
+	var tagged []candidate
+	for _, c := range candidates {
+		if c.depth != depth {
+			continue
+		}
+		shallowest = append(shallowest, c)
+		if c.tagged {
+			tagged = append(tagged, c)
+		}
+	}
+
+	switch {
+	case len(shallowest) == 1:
+		return shallowest[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	default:
+		return candidate{}, false
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// marshalsItself reports whether t has its own JSON form, like time.Time
+func marshalsItself(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return t.Implements(jsonMarshalerType) || pt.Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// isEmptyValue reports whether omitempty drops v, as in encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package gocmdevt
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Address struct {
+	Street string `json:"street"`
+	City   string `json:"city,omitempty"`
+}
+
+type auditInfo struct {
+	Reviewer string `json:"reviewer"`
+}
+
+type ShipmentEvent struct {
+	BaseEvent
+	auditInfo
+	Destination Address    `json:"destination"`
+	Return      *Address   `json:"return_to"`
+	Tags        []string   `json:"tags,omitempty"`
+	Deadline    time.Time  `json:"deadline"`
+	Note        string     `json:"-"`
+	Weight      int        // untagged fields keep their Go name
+	secret      string     // unexported fields are skipped
+	Shipped     *time.Time `json:"shipped,omitzero"`
+}
+
+func (e *ShipmentEvent) Payload() map[string]interface{} {
+	return PayloadOf(e)
+}
+
+func TestPayloadOf(t *testing.T) {
+	deadline := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := &ShipmentEvent{
+		BaseEvent:   NewBaseEvent("Shipment", "order-1", 2),
+		auditInfo:   auditInfo{Reviewer: "bob"},
+		Destination: Address{Street: "Main St 1", City: "Springfield"},
+		Deadline:    deadline,
+		Note:        "fragile",
+		Weight:      3,
+		secret:      "s3cret",
+	}
+
+	t.Run("includes BaseEvent metadata", func(t *testing.T) {
+		payload := event.Payload()
+
+		if payload["id"] != event.ID || payload["type"] != "Shipment" || payload["aggregate_id"] != "order-1" || payload["version"] != 2 {
+			t.Errorf("expected BaseEvent metadata, got %v", payload)
+		}
+		if _, ok := payload["correlation_id"]; ok {
+			t.Errorf("expected empty correlation ID to be omitted, got %v", payload["correlation_id"])
+		}
+	})
+
+	t.Run("follows json tags", func(t *testing.T) {
+		payload := event.Payload()
+
+		if payload["reviewer"] != "bob" {
+			t.Errorf("expected embedded field reviewer, got %v", payload["reviewer"])
+		}
+		if payload["Weight"] != 3 {
+			t.Errorf("expected Weight 3, got %v", payload["Weight"])
+		}
+		for _, key := range []string{"Note", "secret", "tags", "shipped"} {
+			if _, ok := payload[key]; ok {
+				t.Errorf("expected %s to be omitted, got %v", key, payload[key])
+			}
+		}
+	})
+
+	t.Run("maps nested structs", func(t *testing.T) {
+		payload := event.Payload()
+
+		expected := map[string]interface{}{"street": "Main St 1", "city": "Springfield"}
+		if !reflect.DeepEqual(payload["destination"], expected) {
+			t.Errorf("expected %v, got %v", expected, payload["destination"])
+		}
+		if payload["return_to"] != nil {
+			t.Errorf("expected nil return_to, got %v", payload["return_to"])
+		}
+		if payload["deadline"] != deadline {
+			t.Errorf("expected deadline %v, got %v", deadline, payload["deadline"])
+		}
+
+		withReturn := *event
+		withReturn.Return = &Address{Street: "Depot"}
+		expected = map[string]interface{}{"street": "Depot"}
+		if got := withReturn.Payload()["return_to"]; !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("lets shallower fields win", func(t *testing.T) {
+		type inner struct {
+			Name string `json:"name"`
+		}
+		type outer struct {
+			inner
+			Name string `json:"name"`
+		}
+
+		payload := PayloadOf(outer{inner: inner{Name: "inner"}, Name: "outer"})
+		if payload["name"] != "outer" {
+			t.Errorf("expected outer, got %v", payload["name"])
+		}
+	})
+
+	t.Run("handles recursive types", func(t *testing.T) {
+		type node struct {
+			Value int   `json:"value"`
+			Next  *node `json:"next"`
+		}
+
+		payload := PayloadOf(&node{Value: 1, Next: &node{Value: 2}})
+		next := payload["next"].(map[string]interface{})
+		if next["value"] != 2 || next["next"] != nil {
+			t.Errorf("expected nested node 2, got %v", next)
+		}
+	})
+
+	t.Run("covers events without a Payload method", func(t *testing.T) {
+		var event Event = NewUserCreatedEvent("user-1", "John")
+
+		// The promoted BaseEvent.Payload only sees the metadata, under its
+		// own keys
+		payload := event.Payload()
+		if _, ok := payload["name"]; ok {
+			t.Error("expected the promoted Payload to miss the event's fields")
+		}
+		if payload["aggregate"] != "user-1" || payload["correlation"] != "" {
+			t.Errorf("expected the BaseEvent.Payload keys, got %v", payload)
+		}
+		if payload := PayloadOf(event); payload["name"] != "John" || payload["aggregate_id"] != "user-1" {
+			t.Errorf("expected name and aggregate_id, got %v", payload)
+		}
+	})
+
+	t.Run("returns nil for nil and non-structs", func(t *testing.T) {
+		if PayloadOf((*ShipmentEvent)(nil)) != nil || PayloadOf(42) != nil {
+			t.Error("expected nil payloads")
+		}
+	})
+
+	t.Run("is safe for concurrent use", func(t *testing.T) {
+		type fresh struct {
+			A Address `json:"a"`
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if PayloadOf(fresh{})["a"] == nil {
+					t.Error("expected nested payload")
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func BenchmarkPayloadOf(b *testing.B) {
+	event := &ShipmentEvent{BaseEvent: NewBaseEvent("Shipment", "order-1", 1)}
+	for i := 0; i < b.N; i++ {
+		event.Payload()
+	}
+}
@@ -0,0 +1,235 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Header names of the event metadata MessageMapper puts on messages
+const (
+	HeaderEventID       = "event-id"
+	HeaderEventType     = "event-type"
+	HeaderEventVersion  = "event-version"
+	HeaderAggregateID   = "aggregate-id"
+	HeaderOccurredAt    = "occurred-at"
+	HeaderCorrelationID = "correlation-id"
+	HeaderCausationID   = "causation-id"
+	HeaderPrincipal     = "principal"
+	HeaderTenant        = "tenant"
+)
+
+// Message is an event encoded for a message broker
+type Message struct {
+	Topic string
+	// Key is the partition key; brokers keep messages with the same key in order
+	Key     string
+	Headers map[string]string
+	Value   []byte
+}
+
+// Publisher sends messages to a broker. Adapters for Kafka, NATS, AMQP and
+// the like implement it; LoopbackBroker is an in-process one.
+type Publisher interface {
+	// Publish sends messages in order, returning once the broker accepted them.
+	Publish(ctx context.Context, messages ...Message) error
+}
+
+// MessageMapper turns events into messages. The zero value routes events to
+// a topic named after their EventType, keys them by AggregateID and encodes
+// them with JSONCodec.
+type MessageMapper struct {
+	// Topic returns the topic of event; defaults to its EventType
+	Topic func(event Event) string
+	// Codec encodes the message value; defaults to JSONCodec
+	Codec EventCodec
+}
+
+// Message encodes event. Its metadata goes into the headers as well, so
+// brokers can route and filter without decoding the value. Headers the
+// event got from its command are copied, the metadata headers win.
+func (m MessageMapper) Message(event Event) (Message, error) {
+	value, err := m.codec().Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+
+	headers := map[string]string{}
+	if h, ok := event.(eventHeaders); ok {
+		maps.Copy(headers, h.eventHeaders())
+	}
+	headers[HeaderEventID] = event.EventID()
+	headers[HeaderEventType] = event.EventType()
+	headers[HeaderEventVersion] = strconv.Itoa(event.EventVersion())
+	headers[HeaderAggregateID] = event.AggregateID()
+	headers[HeaderOccurredAt] = event.EventTime().Format(time.RFC3339Nano)
+	if c, ok := event.(causalIDs); ok {
+		setHeader(headers, HeaderCorrelationID, c.CorrelationID())
+		setHeader(headers, HeaderCausationID, c.CausationID())
+	}
+
+	topic := event.EventType()
+	if m.Topic != nil {
+		topic = m.Topic(event)
+	}
+	return Message{
+		Topic:   topic,
+		Key:     event.AggregateID(),
+		Headers: headers,
+		Value:   value,
+	}, nil
+}
+
+func (m MessageMapper) codec() EventCodec {
+	if m.Codec == nil {
+		return JSONCodec{}
+	}
+	return m.Codec
+}
+
+// eventHeaders is implemented by events that carry command metadata
+type eventHeaders interface {
+	eventHeaders() map[string]string
+}
+
+// eventHeaders returns the headers, principal and tenant of the command
+// that emitted e
+func (e BaseEvent) eventHeaders() map[string]string {
+	headers := maps.Clone(e.Headers)
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	setHeader(headers, HeaderPrincipal, e.Principal)
+	setHeader(headers, HeaderTenant, e.Tenant)
+	return headers
+}
+
+// setHeader sets non-empty values only
+func setHeader(headers map[string]string, key, value string) {
+	if value != "" {
+		headers[key] = value
+	}
+}
+
+// publish hands event to the Publisher after it was dispatched
+func (e *EventEmitter) publish(ctx context.Context, event Event) error {
+	return NewPublisherDispatcher(e.Publisher, e.MessageMapper).DispatchCtx(ctx, event)
+}
+
+// PublisherDispatcher is a Dispatcher that publishes events to a broker
+// instead of handling them. Hand it to an OutboxRelay to publish the events
+// staged in an Outbox.
+type PublisherDispatcher struct {
+	publisher Publisher
+	mapper    MessageMapper
+}
+
+func NewPublisherDispatcher(publisher Publisher, mapper MessageMapper) *PublisherDispatcher {
+	return &PublisherDispatcher{publisher: publisher, mapper: mapper}
+}
+
+func (d *PublisherDispatcher) Dispatch(event Event) error {
+	return d.DispatchCtx(context.Background(), event)
+}
+
+func (d *PublisherDispatcher) DispatchCtx(ctx context.Context, event Event) error {
+	msg, err := d.mapper.Message(event)
+	if err != nil {
+		return fmt.Errorf("map event %s to message: %w", event.EventID(), err)
+	}
+	if err := d.publisher.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("publish event %s: %w", event.EventID(), err)
+	}
+	return nil
+}
+
+// ###
+
+// MessageHandler receives messages from LoopbackBroker
+type MessageHandler func(ctx context.Context, msg Message) error
+
+// LoopbackBroker is an in-process Publisher and MessageSource for tests. It
+// keeps every published message by topic and hands them to the topic's
+// subscribers before Publish returns.
+type LoopbackBroker struct {
+	mu          sync.RWMutex
+	topics      map[string][]Message
+	subscribers map[string][]MessageHandler
+	published   chan struct{} // closed and replaced on every Publish
+}
+
+func NewLoopbackBroker() *LoopbackBroker {
+	return &LoopbackBroker{
+		topics:      map[string][]Message{},
+		subscribers: map[string][]MessageHandler{},
+		published:   make(chan struct{}),
+	}
+}
+
+// Subscribe adds handler for the messages published to topic from now on
+func (b *LoopbackBroker) Subscribe(topic string, handler MessageHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[topic] = append(b.subscribers[topic], handler)
+}
+
+// Publish records messages and delivers them to subscribers, returning
+// their failures joined.
+func (b *LoopbackBroker) Publish(ctx context.Context, messages ...Message) error {
+	var errs []error
+	for _, msg := range messages {
+		b.mu.Lock()
+		b.topics[msg.Topic] = append(b.topics[msg.Topic], msg)
+		handlers := b.subscribers[msg.Topic]
+		close(b.published)
+		b.published = make(chan struct{})
+		b.mu.Unlock()
+
+		for _, handler := range handlers {
+			if err := handler(ctx, msg); err != nil {
+				errs = append(errs, fmt.Errorf("topic %s: %w", msg.Topic, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Messages returns the messages published to topic, oldest first
+func (b *LoopbackBroker) Messages(topic string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]Message(nil), b.topics[topic]...)
+}
+
+// Fetch returns the messages of topic from offset on, waiting for one to be
+// published if there are none.
+func (b *LoopbackBroker) Fetch(ctx context.Context, topic string, offset int64, limit int) ([]Delivery, error) {
+	offset = max(offset, 0)
+	for {
+		b.mu.RLock()
+		messages, published := b.topics[topic], b.published
+		b.mu.RUnlock()
+
+		if offset < int64(len(messages)) {
+			messages = messages[offset:]
+			if limit > 0 && len(messages) > limit {
+				messages = messages[:limit]
+			}
+			deliveries := make([]Delivery, len(messages))
+			for i, msg := range messages {
+				deliveries[i] = Delivery{Message: msg, Offset: offset + int64(i)}
+			}
+			return deliveries, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-published:
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// failingPublisher rejects every message
+type failingPublisher struct{ err error }
+
+func (p failingPublisher) Publish(ctx context.Context, messages ...Message) error {
+	return p.err
+}
+
+func TestMessageMapper(t *testing.T) {
+	event := NewUserCreatedEvent("user-1", "John")
+	event.SetCausality("corr-1", "cause-1")
+	event.StampCommand(CommandMetadata{Principal: "alice", Tenant: "acme", Headers: map[string]string{"source": "api"}})
+
+	t.Run("routes by event type and keys by aggregate", func(t *testing.T) {
+		msg, err := MessageMapper{}.Message(event)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if msg.Topic != "UserCreated" {
+			t.Errorf("expected topic UserCreated, got %s", msg.Topic)
+		}
+		if msg.Key != "user-1" {
+			t.Errorf("expected key user-1, got %s", msg.Key)
+		}
+	})
+
+	t.Run("maps metadata to headers", func(t *testing.T) {
+		msg, _ := MessageMapper{}.Message(event)
+
+		expected := map[string]string{
+			HeaderEventID:       event.EventID(),
+			HeaderEventType:     "UserCreated",
+			HeaderEventVersion:  "1",
+			HeaderAggregateID:   "user-1",
+			HeaderCorrelationID: "corr-1",
+			HeaderCausationID:   "cause-1",
+			HeaderPrincipal:     "alice",
+			HeaderTenant:        "acme",
+			"source":            "api",
+		}
+		for key, value := range expected {
+			if msg.Headers[key] != value {
+				t.Errorf("expected header %s=%s, got %q", key, value, msg.Headers[key])
+			}
+		}
+		if msg.Headers[HeaderOccurredAt] == "" {
+			t.Error("expected occurred-at header, got none")
+		}
+	})
+
+	t.Run("encodes the value with the codec", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+		msg, _ := MessageMapper{Codec: registry}.Message(event)
+
+		decoded, err := registry.Unmarshal(msg.Value)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if decoded.(*UserCreatedEvent).Name != "John" {
+			t.Errorf("expected John, got %s", decoded.(*UserCreatedEvent).Name)
+		}
+	})
+
+	t.Run("uses a custom topic", func(t *testing.T) {
+		mapper := MessageMapper{Topic: func(e Event) string { return "users." + e.EventType() }}
+		if msg, _ := mapper.Message(event); msg.Topic != "users.UserCreated" {
+			t.Errorf("expected topic users.UserCreated, got %s", msg.Topic)
+		}
+	})
+}
+
+func TestEventEmitter_Publisher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes dispatched events", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		emitter := NewEventEmitter(&recordingLogWriter{}, NewInMemoryDispatcher())
+		emitter.Publisher = broker
+
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+		emitter.EmitVersioned(ctx, "user-1", AnyVersion, NewUserDeletedEvent("user-1"))
+
+		if n := len(broker.Messages("UserCreated")); n != 1 {
+			t.Errorf("expected 1 UserCreated message, got %d", n)
+		}
+		if n := len(broker.Messages("UserDeleted")); n != 1 {
+			t.Errorf("expected 1 UserDeleted message, got %d", n)
+		}
+	})
+
+	t.Run("returns publish failures", func(t *testing.T) {
+		errBroker := errors.New("broker down")
+		emitter := NewEventEmitter(&recordingLogWriter{}, NewInMemoryDispatcher())
+		emitter.Publisher = failingPublisher{errBroker}
+
+		if err := emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John")); !errors.Is(err, errBroker) {
+			t.Errorf("expected %v, got %v", errBroker, err)
+		}
+	})
+
+	t.Run("does not publish after a failure under StopOnFirstError", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		dispatcher := NewInMemoryDispatcher()
+		dispatcher.SubscribeAll(func(ctx context.Context, e Event) (any, error) {
+			return nil, errors.New("handler failed")
+		})
+		emitter := NewEventEmitter(&recordingLogWriter{}, dispatcher)
+		emitter.Publisher = broker
+		emitter.ErrorPolicy = StopOnFirstError
+
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+
+		if n := len(broker.Messages("UserCreated")); n != 0 {
+			t.Errorf("expected no messages, got %d", n)
+		}
+	})
+}
+
+func TestPublisherDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes events relayed from an outbox", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		emitter, _, relay := newOutboxEmitter(NewPublisherDispatcher(broker, MessageMapper{}), OutboxRelayConfig{})
+		event := NewUserCreatedEvent("user-1", "John")
+
+		if err := emitter.EmitCtx(ctx, event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := len(broker.Messages("UserCreated")); n != 0 {
+			t.Fatalf("expected nothing published before relaying, got %d", n)
+		}
+		if n, err := relay.RelayPending(ctx); err != nil || n != 1 {
+			t.Fatalf("expected 1 relayed, got %d, %v", n, err)
+		}
+
+		messages := broker.Messages("UserCreated")
+		if len(messages) != 1 || messages[0].Headers[HeaderEventID] != event.EventID() {
+			t.Errorf("expected %s to be published, got %v", event.EventID(), messages)
+		}
+	})
+
+	t.Run("keeps entries whose publish failed", func(t *testing.T) {
+		errBroker := errors.New("broker down")
+		var hooked []error
+		emitter, outbox, relay := newOutboxEmitter(NewPublisherDispatcher(failingPublisher{errBroker}, MessageMapper{}), OutboxRelayConfig{
+			ErrorHook: func(ctx context.Context, event Event, err error) { hooked = append(hooked, err) },
+		})
+		emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+
+		if n, err := relay.RelayPending(ctx); err != nil || n != 0 {
+			t.Fatalf("expected nothing relayed, got %d, %v", n, err)
+		}
+		if len(hooked) != 1 || !errors.Is(hooked[0], errBroker) {
+			t.Errorf("expected %v to be reported, got %v", errBroker, hooked)
+		}
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 1 || pending[0].Attempts != 1 {
+			t.Errorf("expected the entry to stay pending after 1 attempt, got %v", pending)
+		}
+	})
+}
+
+func TestLoopbackBroker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivers to subscribers of the topic", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		var received []string
+		broker.Subscribe("orders", func(ctx context.Context, msg Message) error {
+			received = append(received, msg.Key)
+			return nil
+		})
+
+		broker.Publish(ctx, Message{Topic: "orders", Key: "order-1"}, Message{Topic: "users", Key: "user-1"})
+
+		if len(received) != 1 || received[0] != "order-1" {
+			t.Errorf("expected [order-1], got %v", received)
+		}
+		if n := len(broker.Messages("users")); n != 1 {
+			t.Errorf("expected 1 users message, got %d", n)
+		}
+	})
+
+	t.Run("returns subscriber failures", func(t *testing.T) {
+		broker := NewLoopbackBroker()
+		errHandler := errors.New("handler failed")
+		broker.Subscribe("orders", func(ctx context.Context, msg Message) error { return errHandler })
+
+		if err := broker.Publish(ctx, Message{Topic: "orders"}); !errors.Is(err, errHandler) {
+			t.Errorf("expected %v, got %v", errHandler, err)
+		}
+	})
+}
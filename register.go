@@ -0,0 +1,48 @@
+package gocmdevt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TypedHandlerFunc is a command handler with a concrete command and result type
+type TypedHandlerFunc[C Command, R any] func(ctx context.Context, cmd C) (R, error)
+
+// Handler adapts a typed handler into a HandlerFunc so it can be returned
+// from Module.Handlers alongside untyped handlers.
+func Handler[C Command, R any](h TypedHandlerFunc[C, R]) HandlerFunc {
+	return func(ctx context.Context, cmd Command) (any, error) {
+		typed, ok := cmd.(C)
+		if !ok {
+			return nil, fmt.Errorf("invalid command type: expected %v, got %T", reflect.TypeFor[C](), cmd)
+		}
+		return h(ctx, typed)
+	}
+}
+
+// Register adds a typed handler for commands of type C to the app, applying
+// the app's conflict policy. C is the exact type passed to Handle, usually a
+// pointer such as *CreateOrderCommand.
+func Register[C Command, R any](app *App, h TypedHandlerFunc[C, R]) error {
+	typ := reflect.TypeFor[C]()
+	return app.register(fmt.Sprintf("Register[%v]", typ), map[reflect.Type]HandlerFunc{typ: Handler(h)})
+}
+
+// Send handles cmd through the app and returns its result as R.
+// A nil result yields the zero value of R.
+func Send[R any](ctx context.Context, app *App, cmd Command) (R, error) {
+	var zero R
+	result, err := app.Handle(ctx, cmd)
+	if result == nil {
+		return zero, err
+	}
+	typed, ok := result.(R)
+	if !ok {
+		if err != nil {
+			return zero, err
+		}
+		return zero, fmt.Errorf("unexpected result type for command %T: expected %v, got %T", cmd, reflect.TypeFor[R](), result)
+	}
+	return typed, err
+}
@@ -0,0 +1,160 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type createdUser struct {
+	ID   string
+	Name string
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("routes typed command to typed handler", func(t *testing.T) {
+		app := NewApp()
+		Register(app, func(ctx context.Context, cmd *CreateUserCommand) (createdUser, error) {
+			return createdUser{ID: "123", Name: cmd.Name}, nil
+		})
+
+		user, err := Send[createdUser](context.Background(), app, &CreateUserCommand{Name: "John"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if user.ID != "123" || user.Name != "John" {
+			t.Errorf("expected user {123 John}, got %+v", user)
+		}
+	})
+
+	t.Run("registers in the same table as modules", func(t *testing.T) {
+		app := NewApp(NewUserModule())
+		Register(app, func(ctx context.Context, cmd *UpdateUserCommand) (int, error) {
+			return cmd.ID, nil
+		})
+
+		if len(app.snapshot().handlers) != 3 {
+			t.Errorf("expected 3 handlers, got %d", len(app.snapshot().handlers))
+		}
+
+		result, err := app.Handle(context.Background(), &UpdateUserCommand{ID: 7})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result != 7 {
+			t.Errorf("expected 7, got %v", result)
+		}
+	})
+
+	t.Run("distinguishes value and pointer command types", func(t *testing.T) {
+		app := NewApp()
+		Register(app, func(ctx context.Context, cmd CreateUserCommand) (string, error) {
+			return "value", nil
+		})
+
+		if _, ok := app.snapshot().handlers[reflect.TypeOf(CreateUserCommand{})]; !ok {
+			t.Error("expected handler for value type to be registered")
+		}
+
+		if _, err := app.Handle(context.Background(), &CreateUserCommand{}); err == nil {
+			t.Error("expected error for unregistered pointer type, got nil")
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("rejects mismatched command type", func(t *testing.T) {
+		h := Handler(func(ctx context.Context, cmd *CreateUserCommand) (string, error) {
+			return cmd.Name, nil
+		})
+
+		_, err := h(context.Background(), &DeleteUserCommand{ID: 1})
+		if err == nil {
+			t.Fatal("expected error for mismatched command type, got nil")
+		}
+
+		if !strings.Contains(err.Error(), "invalid command type") {
+			t.Errorf("expected invalid command type error, got '%s'", err.Error())
+		}
+	})
+
+	t.Run("can be returned from Module.Handlers", func(t *testing.T) {
+		app := NewApp(moduleFunc(func() map[reflect.Type]HandlerFunc {
+			return map[reflect.Type]HandlerFunc{
+				reflect.TypeOf(&CreateUserCommand{}): Handler(func(ctx context.Context, cmd *CreateUserCommand) (string, error) {
+					return "typed " + cmd.Name, nil
+				}),
+			}
+		}))
+
+		name, err := Send[string](context.Background(), app, &CreateUserCommand{Name: "Jane"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if name != "typed Jane" {
+			t.Errorf("expected 'typed Jane', got '%s'", name)
+		}
+	})
+}
+
+func TestSend(t *testing.T) {
+	app := NewApp(NewUserModule())
+	ctx := context.Background()
+
+	t.Run("returns error on result type mismatch", func(t *testing.T) {
+		_, err := Send[string](ctx, app, &CreateUserCommand{Name: "John"})
+		if err == nil {
+			t.Fatal("expected error for result type mismatch, got nil")
+		}
+
+		if !strings.Contains(err.Error(), "unexpected result type") {
+			t.Errorf("expected unexpected result type error, got '%s'", err.Error())
+		}
+	})
+
+	t.Run("returns zero value for nil result", func(t *testing.T) {
+		Register(app, func(ctx context.Context, cmd *UpdateUserCommand) (any, error) {
+			return nil, nil
+		})
+
+		result, err := Send[*createdUser](ctx, app, &UpdateUserCommand{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result != nil {
+			t.Errorf("expected nil result, got %v", result)
+		}
+	})
+
+	t.Run("returns handler error", func(t *testing.T) {
+		wantErr := errors.New("boom")
+		Register(app, func(ctx context.Context, cmd *DeleteUserCommand) (int, error) {
+			return 0, wantErr
+		})
+
+		_, err := Send[int](ctx, app, &DeleteUserCommand{})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("expected %v, got %v", wantErr, err)
+		}
+	})
+
+	t.Run("returns error for unregistered command", func(t *testing.T) {
+		_, err := Send[int](ctx, NewApp(), &DeleteUserCommand{})
+		if err == nil {
+			t.Fatal("expected error for unregistered command, got nil")
+		}
+	})
+}
+
+// moduleFunc adapts a function into a Module
+type moduleFunc func() map[reflect.Type]HandlerFunc
+
+func (f moduleFunc) Handlers() map[reflect.Type]HandlerFunc {
+	return f()
+}
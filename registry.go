@@ -0,0 +1,227 @@
+package gocmdevt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownEventType is returned when decoding an event that is not registered
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// eventTypeKey identifies an event schema
+type eventTypeKey struct {
+	eventType string
+	version   int
+}
+
+// Upcaster transforms the JSON fields of an event from one schema version
+// to the next. The registry updates the "version" field itself. Numbers are
+// json.Number values, so they keep their full precision.
+type Upcaster func(data map[string]any) (map[string]any, error)
+
+// EventRegistry maps EventType and EventVersion to Go types so stored events
+// can be decoded back into their concrete structs. Events stored at an older
+// version are upcast to the latest registered version of their type.
+//
+// It is an EventCodec using the same envelope as JSONCodec, so events written
+// by either can be read by both.
+type EventRegistry struct {
+	mu        sync.RWMutex
+	types     map[eventTypeKey]reflect.Type
+	upcasters map[eventTypeKey]Upcaster // keyed by the version upcast from
+}
+
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		types:     make(map[eventTypeKey]reflect.Type),
+		upcasters: make(map[eventTypeKey]Upcaster),
+	}
+}
+
+// Register maps eventType at version to the Go type of prototype. Pointer
+// prototypes such as &OrderCreatedEvent{} decode to pointers.
+func (r *EventRegistry) Register(eventType string, version int, prototype Event) error {
+	typ := reflect.TypeOf(prototype)
+	if typ == nil {
+		return fmt.Errorf("register %s v%d: nil prototype", eventType, version)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := eventTypeKey{eventType, version}
+	if existing, ok := r.types[key]; ok {
+		return fmt.Errorf("register %s v%d: already registered as %v", eventType, version, existing)
+	}
+	r.types[key] = typ
+	return nil
+}
+
+// RegisterEvent maps eventType at version to E.
+func RegisterEvent[E Event](r *EventRegistry, eventType string, version int) error {
+	var zero E
+	typ := reflect.TypeFor[E]()
+	if typ.Kind() == reflect.Pointer {
+		return r.Register(eventType, version, reflect.New(typ.Elem()).Interface().(Event))
+	}
+	return r.Register(eventType, version, zero)
+}
+
+// RegisterUpcaster adds the step that turns eventType data at fromVersion
+// into fromVersion+1.
+func (r *EventRegistry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := eventTypeKey{eventType, fromVersion}
+	if _, ok := r.upcasters[key]; ok {
+		return fmt.Errorf("register upcaster %s v%d: already registered", eventType, fromVersion)
+	}
+	r.upcasters[key] = upcaster
+	return nil
+}
+
+// Validate checks that every known older version of each event type has a
+// gap-free upcaster chain to the type's latest registered version.
+func (r *EventRegistry) Validate() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var problems []string
+	latestVersions := r.latestVersions()
+	for eventType, latest := range latestVersions {
+		for key := range r.versionsOf(eventType) {
+			if key.version > latest {
+				problems = append(problems, fmt.Sprintf("%s: upcaster from v%d is past latest version v%d", eventType, key.version, latest))
+				continue
+			}
+			for v := key.version; v < latest; v++ {
+				if _, ok := r.upcasters[eventTypeKey{eventType, v}]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: no upcaster from v%d to v%d", eventType, v, v+1))
+					break
+				}
+			}
+		}
+	}
+	for key := range r.upcasters {
+		if _, ok := latestVersions[key.eventType]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: upcasters without a registered type", key.eventType))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid event registry: %s", strings.Join(problems, "; "))
+}
+
+// latestVersions returns the highest registered version per event type; the caller holds mu
+func (r *EventRegistry) latestVersions() map[string]int {
+	latest := map[string]int{}
+	for key := range r.types {
+		if v, ok := latest[key.eventType]; !ok || key.version > v {
+			latest[key.eventType] = key.version
+		}
+	}
+	return latest
+}
+
+// versionsOf returns the registered and upcast-from versions of eventType; the caller holds mu
+func (r *EventRegistry) versionsOf(eventType string) map[eventTypeKey]bool {
+	keys := map[eventTypeKey]bool{}
+	for key := range r.types {
+		if key.eventType == eventType {
+			keys[key] = true
+		}
+	}
+	for key := range r.upcasters {
+		if key.eventType == eventType {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// resolve returns the Go type data should be decoded into, upcasting data
+// from version to the latest registered version of eventType when needed
+func (r *EventRegistry) resolve(eventType string, version int, data json.RawMessage) (reflect.Type, json.RawMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest, ok := r.latestVersions()[eventType]
+	if !ok || version > latest {
+		return nil, nil, fmt.Errorf("%w: %s v%d", ErrUnknownEventType, eventType, version)
+	}
+	if version == latest {
+		return r.types[eventTypeKey{eventType, latest}], data, nil
+	}
+
+	// Numbers stay json.Number so large integers survive the round trip
+	fields := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal %s v%d data: %w", eventType, version, err)
+	}
+	for v := version; v < latest; v++ {
+		upcast, ok := r.upcasters[eventTypeKey{eventType, v}]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s v%d has no upcaster to v%d", ErrUnknownEventType, eventType, v, v+1)
+		}
+		var err error
+		if fields, err = upcast(fields); err != nil {
+			return nil, nil, fmt.Errorf("upcast %s v%d: %w", eventType, v, err)
+		}
+		fields["version"] = v + 1
+	}
+
+	upcasted, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal upcast %s: %w", eventType, err)
+	}
+	return r.types[eventTypeKey{eventType, latest}], upcasted, nil
+}
+
+// Marshal encodes event with its BaseEvent metadata in a JSON envelope.
+func (r *EventRegistry) Marshal(event Event) ([]byte, error) {
+	return JSONCodec{}.Marshal(event)
+}
+
+// Unmarshal decodes an envelope into the Go type registered for the latest
+// version of its event type, upcasting older versions on the way.
+func (r *EventRegistry) Unmarshal(data []byte) (Event, error) {
+	var env eventEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("unmarshal event envelope: %w", err)
+	}
+
+	typ, eventData, err := r.resolve(env.Type, env.Version, env.Data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEvent(typ, eventData)
+}
+
+// decodeEvent decodes data into a new value of typ
+func decodeEvent(typ reflect.Type, data []byte) (Event, error) {
+	isPointer := typ.Kind() == reflect.Pointer
+	if isPointer {
+		typ = typ.Elem()
+	}
+
+	ptr := reflect.New(typ)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("unmarshal %v: %w", typ, err)
+	}
+	if isPointer {
+		return ptr.Interface().(Event), nil
+	}
+	return ptr.Elem().Interface().(Event), nil
+}
@@ -0,0 +1,286 @@
+package gocmdevt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEventRegistry(t *testing.T) {
+	t.Run("round-trips typed events", func(t *testing.T) {
+		registry := NewEventRegistry()
+		if err := RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		event := NewUserCreatedEvent("user-1", "John")
+		data, err := registry.Marshal(event)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		typed, ok := decoded.(*UserCreatedEvent)
+		if !ok {
+			t.Fatalf("expected *UserCreatedEvent, got %T", decoded)
+		}
+
+		if typed.Name != "John" || typed.EventID() != event.EventID() || typed.AggregateID() != "user-1" {
+			t.Errorf("expected %+v, got %+v", event, typed)
+		}
+
+		if !typed.EventTime().Equal(event.EventTime()) {
+			t.Errorf("expected time %v, got %v", event.EventTime(), typed.EventTime())
+		}
+	})
+
+	t.Run("decodes value registrations to values", func(t *testing.T) {
+		registry := NewEventRegistry()
+		registry.Register("UserDeleted", 1, UserDeletedEvent{})
+
+		data, _ := registry.Marshal(NewUserDeletedEvent("user-1"))
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := decoded.(UserDeletedEvent); !ok {
+			t.Errorf("expected UserDeletedEvent value, got %T", decoded)
+		}
+	})
+
+	t.Run("distinguishes event versions", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+
+		v2 := &UserCreatedEvent{BaseEvent: NewBaseEvent("UserCreated", "user-1", 2)}
+		data, _ := registry.Marshal(v2)
+
+		if _, err := registry.Unmarshal(data); !errors.Is(err, ErrUnknownEventType) {
+			t.Errorf("expected ErrUnknownEventType, got %v", err)
+		}
+	})
+
+	t.Run("rejects duplicate registration", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+
+		if err := RegisterEvent[*UserDeletedEvent](registry, "UserCreated", 1); err == nil {
+			t.Error("expected error for duplicate registration, got nil")
+		}
+	})
+
+	t.Run("reads events written by JSONCodec", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+
+		data, _ := JSONCodec{}.Marshal(NewUserCreatedEvent("user-1", "John"))
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if decoded.(*UserCreatedEvent).Name != "John" {
+			t.Errorf("expected name John, got %+v", decoded)
+		}
+	})
+
+	t.Run("lets a file store load typed events", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*UserCreatedEvent](registry, "UserCreated", 1)
+		store, err := OpenFileEventStore(t.TempDir(), FileEventStoreConfig{Codec: registry})
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		defer store.Close()
+
+		store.Write(NewUserCreatedEvent("user-1", "John"))
+		stored, err := store.Load(context.Background(), "user-1", 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, ok := stored[0].Event.(*UserCreatedEvent); !ok {
+			t.Errorf("expected *UserCreatedEvent, got %T", stored[0].Event)
+		}
+	})
+}
+
+// orderCreatedV1 is the original schema of OrderCreated, before currencies
+type orderCreatedV1 struct {
+	BaseEvent
+	Amount int `json:"amount"`
+}
+
+type OrderCreatedEvent struct {
+	BaseEvent
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func addCurrency(data map[string]any) (map[string]any, error) {
+	data["currency"] = "USD"
+	return data, nil
+}
+
+func newOrderRegistry(t *testing.T) *EventRegistry {
+	t.Helper()
+	registry := NewEventRegistry()
+	if err := RegisterEvent[*OrderCreatedEvent](registry, "OrderCreated", 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.RegisterUpcaster("OrderCreated", 1, addCurrency); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return registry
+}
+
+func TestEventRegistry_Upcasting(t *testing.T) {
+	t.Run("loads old events as the current type", func(t *testing.T) {
+		registry := newOrderRegistry(t)
+
+		old := &orderCreatedV1{BaseEvent: NewBaseEvent("OrderCreated", "order-1", 1), Amount: 42}
+		data, _ := JSONCodec{}.Marshal(old)
+
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		order, ok := decoded.(*OrderCreatedEvent)
+		if !ok {
+			t.Fatalf("expected *OrderCreatedEvent, got %T", decoded)
+		}
+
+		if order.Amount != 42 || order.Currency != "USD" {
+			t.Errorf("expected amount 42 in USD, got %d in %q", order.Amount, order.Currency)
+		}
+
+		if order.EventVersion() != 2 {
+			t.Errorf("expected version 2, got %d", order.EventVersion())
+		}
+
+		if order.EventID() != old.EventID() {
+			t.Errorf("expected ID %s, got %s", old.EventID(), order.EventID())
+		}
+	})
+
+	t.Run("applies chained upcasters in order", func(t *testing.T) {
+		registry := newOrderRegistry(t)
+		RegisterEvent[*OrderCreatedEvent](registry, "OrderCreated", 3)
+		registry.RegisterUpcaster("OrderCreated", 2, func(data map[string]any) (map[string]any, error) {
+			amount, err := data["amount"].(json.Number).Int64()
+			data["amount"] = amount * 100 // cents
+			return data, err
+		})
+
+		data, _ := JSONCodec{}.Marshal(&orderCreatedV1{BaseEvent: NewBaseEvent("OrderCreated", "order-1", 1), Amount: 5})
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		order := decoded.(*OrderCreatedEvent)
+		if order.Amount != 500 || order.Currency != "USD" || order.EventVersion() != 3 {
+			t.Errorf("expected 500 USD at v3, got %+v", order)
+		}
+	})
+
+	t.Run("keeps the precision of large integers", func(t *testing.T) {
+		type ledgerEntryV1 struct {
+			BaseEvent
+			Amount int64 `json:"amount"`
+		}
+		type LedgerEntryEvent struct {
+			BaseEvent
+			Amount int64  `json:"amount"`
+			Memo   string `json:"memo"`
+		}
+		registry := NewEventRegistry()
+		RegisterEvent[*LedgerEntryEvent](registry, "LedgerEntry", 2)
+		registry.RegisterUpcaster("LedgerEntry", 1, func(data map[string]any) (map[string]any, error) {
+			data["memo"] = "migrated"
+			return data, nil
+		})
+
+		const amount = 9007199254740993 // 2^53 + 1, not representable as float64
+		data, _ := JSONCodec{}.Marshal(&ledgerEntryV1{BaseEvent: NewBaseEvent("LedgerEntry", "ledger-1", 1), Amount: amount})
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := decoded.(*LedgerEntryEvent).Amount; got != amount {
+			t.Errorf("expected %d, got %d", int64(amount), got)
+		}
+	})
+
+	t.Run("decodes current events without upcasting", func(t *testing.T) {
+		registry := newOrderRegistry(t)
+
+		current := &OrderCreatedEvent{BaseEvent: NewBaseEvent("OrderCreated", "order-1", 2), Amount: 7, Currency: "EUR"}
+		data, _ := registry.Marshal(current)
+		decoded, err := registry.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if decoded.(*OrderCreatedEvent).Currency != "EUR" {
+			t.Errorf("expected currency EUR, got %+v", decoded)
+		}
+	})
+
+	t.Run("reports upcaster errors", func(t *testing.T) {
+		registry := NewEventRegistry()
+		RegisterEvent[*OrderCreatedEvent](registry, "OrderCreated", 2)
+		boom := errors.New("boom")
+		registry.RegisterUpcaster("OrderCreated", 1, func(map[string]any) (map[string]any, error) {
+			return nil, boom
+		})
+
+		data, _ := JSONCodec{}.Marshal(&orderCreatedV1{BaseEvent: NewBaseEvent("OrderCreated", "order-1", 1)})
+		if _, err := registry.Unmarshal(data); !errors.Is(err, boom) {
+			t.Errorf("expected upcaster error, got %v", err)
+		}
+	})
+
+	t.Run("rejects duplicate upcasters", func(t *testing.T) {
+		registry := newOrderRegistry(t)
+
+		if err := registry.RegisterUpcaster("OrderCreated", 1, addCurrency); err == nil {
+			t.Error("expected error for duplicate upcaster, got nil")
+		}
+	})
+
+	t.Run("validates gap-free chains", func(t *testing.T) {
+		registry := newOrderRegistry(t)
+		if err := registry.Validate(); err != nil {
+			t.Errorf("expected valid registry, got %v", err)
+		}
+
+		// v3 is registered but nothing upcasts v2 to it
+		RegisterEvent[*OrderCreatedEvent](registry, "OrderCreated", 3)
+		if err := registry.Validate(); err == nil {
+			t.Error("expected gap error, got nil")
+		}
+
+		data, _ := JSONCodec{}.Marshal(&orderCreatedV1{BaseEvent: NewBaseEvent("OrderCreated", "order-1", 1)})
+		if _, err := registry.Unmarshal(data); !errors.Is(err, ErrUnknownEventType) {
+			t.Errorf("expected ErrUnknownEventType, got %v", err)
+		}
+	})
+
+	t.Run("validates upcasters have a registered type", func(t *testing.T) {
+		registry := NewEventRegistry()
+		registry.RegisterUpcaster("OrderCreated", 1, addCurrency)
+
+		if err := registry.Validate(); err == nil {
+			t.Error("expected error for unregistered type, got nil")
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy retries a failed event handler with exponential backoff. The
+// delay before retry n is InitialBackoff * Multiplier^(n-1), capped at
+// MaxBackoff and spread by Jitter. Attach it with Subscription.WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls including the first; defaults to 3
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; defaults to 100ms
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay; defaults to 30 seconds
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry; defaults to 2
+	Multiplier float64
+	// Jitter varies each delay randomly by up to this fraction of it, so
+	// 0.2 waits between 80% and 120%; zero waits exactly
+	Jitter float64
+	// Retryable reports whether a failure is worth retrying; defaults to
+	// IsRetryable
+	Retryable func(err error) bool
+	// Clock paces the backoff; defaults to DefaultClock
+	Clock Clock
+}
+
+// withDefaults returns p with its zero fields set to their defaults
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	p.Clock = clockOrDefault(p.Clock)
+	return p
+}
+
+// backoff returns the delay before retry n (from 1), with random in [0, 1)
+// drawn for the jitter
+func (p RetryPolicy) backoff(n int, random float64) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < n && delay < float64(p.MaxBackoff); i++ {
+		delay *= p.Multiplier
+	}
+	delay = min(delay, float64(p.MaxBackoff))
+	delay *= 1 + p.Jitter*(2*random-1)
+	return time.Duration(delay)
+}
+
+// run calls fn until it succeeds, fails with an error that is not
+// retryable, runs out of attempts or ctx is done, returning the number of
+// calls and the last failure.
+func (p RetryPolicy) run(ctx context.Context, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, errors.Join(err, ctx.Err())
+		case <-p.Clock.After(p.backoff(attempt, rand.Float64())):
+		}
+	}
+}
+
+// PermanentError marks a handler failure that retrying cannot fix
+type PermanentError struct {
+	Err error
+}
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable is the default retry classification: every failure except a
+// PermanentError and the cancellation or expiry of a context.
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	return !errors.As(err, &permanent) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,151 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leviplj/go-cmd-evt/clocktest"
+)
+
+// flakyHandler fails its first failures calls with err and counts all calls
+func flakyHandler(failures int, err error, calls *int) EventHandlerFunc {
+	return func(ctx context.Context, e Event) (any, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, err
+		}
+		return nil, nil
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	errTransient := errors.New("connection reset")
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("retries with exponential backoff until the handler succeeds", func(t *testing.T) {
+		clock := clocktest.New(start)
+		d := NewInMemoryDispatcher()
+		var calls int
+		d.Subscribe(&UserCreatedEvent{}, flakyHandler(2, errTransient, &calls)).
+			WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, Clock: clock})
+
+		done := make(chan error)
+		go func() { done <- d.Dispatch(NewUserCreatedEvent("user-1", "John")) }()
+
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+		if clock.Waiters() != 1 {
+			t.Fatal("expected the second retry to wait twice as long")
+		}
+		clock.Advance(time.Second)
+
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("reports the failure after MaxAttempts", func(t *testing.T) {
+		clock := clocktest.New(start)
+		d := NewInMemoryDispatcher()
+		var calls int
+		d.Subscribe(&UserCreatedEvent{}, flakyHandler(5, errTransient, &calls)).
+			WithRetry(RetryPolicy{MaxAttempts: 2, Clock: clock})
+
+		done := make(chan error)
+		go func() { done <- d.Dispatch(NewUserCreatedEvent("user-1", "John")) }()
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second)
+
+		var failure *HandlerError
+		if err := <-done; !errors.As(err, &failure) || !errors.Is(err, errTransient) {
+			t.Fatalf("expected HandlerError wrapping %v, got %v", errTransient, err)
+		}
+		if failure.Attempts != 2 || calls != 2 {
+			t.Errorf("expected 2 attempts, got %d after %d calls", failure.Attempts, calls)
+		}
+	})
+
+	t.Run("does not retry permanent failures", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls int
+		d.Subscribe(&UserCreatedEvent{}, flakyHandler(1, Permanent(errTransient), &calls)).
+			WithRetry(RetryPolicy{})
+
+		err := d.Dispatch(NewUserCreatedEvent("user-1", "John"))
+		if !errors.Is(err, errTransient) || calls != 1 {
+			t.Errorf("expected one failed call, got %d and %v", calls, err)
+		}
+	})
+
+	t.Run("uses a custom classification", func(t *testing.T) {
+		d := NewInMemoryDispatcher()
+		var calls int
+		d.Subscribe(&UserCreatedEvent{}, flakyHandler(1, errTransient, &calls)).
+			WithRetry(RetryPolicy{Retryable: func(err error) bool { return false }})
+
+		if err := d.Dispatch(NewUserCreatedEvent("user-1", "John")); err == nil || calls != 1 {
+			t.Errorf("expected one failed call, got %d and %v", calls, err)
+		}
+	})
+
+	t.Run("stops retrying when ctx is done", func(t *testing.T) {
+		clock := clocktest.New(start)
+		d := NewInMemoryDispatcher()
+		var calls int
+		d.Subscribe(&UserCreatedEvent{}, flakyHandler(5, errTransient, &calls)).
+			WithRetry(RetryPolicy{MaxAttempts: 5, Clock: clock})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error)
+		go func() { done <- d.DispatchCtx(ctx, NewUserCreatedEvent("user-1", "John")) }()
+		clock.BlockUntilWaiters(1)
+		cancel()
+
+		err := <-done
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, errTransient) {
+			t.Errorf("expected the failure and context.Canceled, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("caps and spreads the backoff", func(t *testing.T) {
+		policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: 0.5}.withDefaults()
+
+		expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+		for i, want := range expected {
+			if got := policy.backoff(i+1, 0.5); got != want {
+				t.Errorf("retry %d: expected %v, got %v", i+1, want, got)
+			}
+		}
+		if low, high := policy.backoff(1, 0), policy.backoff(1, 0.999); low != 500*time.Millisecond || high < 1490*time.Millisecond {
+			t.Errorf("expected jitter between 500ms and 1.5s, got %v and %v", low, high)
+		}
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{errors.New("timeout"), true},
+		{&ConcurrencyError{AggregateID: "user-1"}, true},
+		{Permanent(errors.New("invalid")), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, c := range cases {
+		if got := IsRetryable(c.err); got != c.retryable {
+			t.Errorf("%v: expected %v, got %v", c.err, c.retryable, got)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package gocmdevt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Snapshot is a serialized aggregate state at a given version
+type Snapshot struct {
+	AggregateID string
+	// Version is the aggregate version the state was captured at
+	Version int
+	// SchemaVersion is the format of Data; snapshots whose SchemaVersion
+	// differs from the aggregate's current one are ignored
+	SchemaVersion int
+	Data          []byte
+	Time          time.Time
+}
+
+// SnapshotStore keeps the latest snapshot per aggregate
+type SnapshotStore interface {
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+	// LoadSnapshot returns nil without error when the aggregate has no snapshot
+	LoadSnapshot(ctx context.Context, aggregateID string) (*Snapshot, error)
+}
+
+// Snapshotter is implemented by aggregates that can be restored from a snapshot
+type Snapshotter interface {
+	// SnapshotVersion is the current format of the snapshot data
+	SnapshotVersion() int
+	MarshalSnapshot() ([]byte, error)
+	UnmarshalSnapshot(data []byte) error
+}
+
+// SnapshotPolicy decides after a save whether to snapshot an aggregate that
+// moved from fromVersion to its current version
+type SnapshotPolicy func(root *AggregateRoot, fromVersion int) bool
+
+// SnapshotEvery snapshots each time an aggregate's version crosses a multiple of n.
+func SnapshotEvery(n int) SnapshotPolicy {
+	return func(root *AggregateRoot, fromVersion int) bool {
+		return n > 0 && root.Version()/n > fromVersion/n
+	}
+}
+
+// ###
+
+// InMemorySnapshotStore is a SnapshotStore kept in process memory
+type InMemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]Snapshot
+}
+
+func NewInMemorySnapshotStore() *InMemorySnapshotStore {
+	return &InMemorySnapshotStore{
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+// SaveSnapshot keeps snapshot unless a newer one is already stored.
+func (s *InMemorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cur, ok := s.snapshots[snapshot.AggregateID]; ok && cur.Version > snapshot.Version {
+		return nil
+	}
+	snapshot.Data = append([]byte(nil), snapshot.Data...)
+	s.snapshots[snapshot.AggregateID] = snapshot
+	return nil
+}
+
+func (s *InMemorySnapshotStore) LoadSnapshot(ctx context.Context, aggregateID string) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, ok := s.snapshots[aggregateID]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
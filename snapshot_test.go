@@ -0,0 +1,206 @@
+package gocmdevt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/leviplj/go-cmd-evt/clocktest"
+)
+
+// SnapshotAccount is an Account that can be restored from snapshots
+type SnapshotAccount struct {
+	*Account
+	schema int
+}
+
+func NewSnapshotAccount(id string) *SnapshotAccount {
+	return &SnapshotAccount{Account: NewAccount(id), schema: 1}
+}
+
+func (a *SnapshotAccount) SnapshotVersion() int {
+	return a.schema
+}
+
+func (a *SnapshotAccount) MarshalSnapshot() ([]byte, error) {
+	return json.Marshal(map[string]any{"owner": a.Owner, "balance": a.Balance})
+}
+
+func (a *SnapshotAccount) UnmarshalSnapshot(data []byte) error {
+	var state struct {
+		Owner   string `json:"owner"`
+		Balance int    `json:"balance"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	a.Owner, a.Balance = state.Owner, state.Balance
+	return nil
+}
+
+// countingEventStore records the versions each Load starts from
+type countingEventStore struct {
+	*InMemoryEventStore
+	loadedFrom []int
+	loaded     int
+}
+
+func (s *countingEventStore) Load(ctx context.Context, aggregateID string, fromVersion int) ([]StoredEvent, error) {
+	stored, err := s.InMemoryEventStore.Load(ctx, aggregateID, fromVersion)
+	s.loadedFrom = append(s.loadedFrom, fromVersion)
+	s.loaded += len(stored)
+	return stored, err
+}
+
+func newSnapshotRepository(policy SnapshotPolicy) (*Repository[*SnapshotAccount], *countingEventStore, *InMemorySnapshotStore) {
+	store := &countingEventStore{InMemoryEventStore: NewInMemoryEventStore()}
+	snapshots := NewInMemorySnapshotStore()
+	repo := NewRepository(store, NewEventEmitter(NewEventStoreWriter(store), NewInMemoryDispatcher()), NewSnapshotAccount)
+	repo.SetSnapshots(snapshots, policy)
+	return repo, store, snapshots
+}
+
+func TestRepository_Snapshots(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("snapshots every n events and replays only later events", func(t *testing.T) {
+		repo, store, snapshots := newSnapshotRepository(SnapshotEvery(3))
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+		account.Deposit(10)
+		repo.Save(ctx, account)
+
+		if snapshot, _ := snapshots.LoadSnapshot(ctx, "acc-1"); snapshot != nil {
+			t.Fatalf("expected no snapshot at version 2, got version %d", snapshot.Version)
+		}
+
+		account.Deposit(20)
+		account.Deposit(30)
+		repo.Save(ctx, account)
+
+		snapshot, _ := snapshots.LoadSnapshot(ctx, "acc-1")
+		if snapshot == nil || snapshot.Version != 4 {
+			t.Fatalf("expected snapshot at version 4, got %v", snapshot)
+		}
+
+		account.Deposit(5)
+		repo.Save(ctx, account)
+
+		store.loaded = 0
+		loaded, err := repo.Load(ctx, "acc-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if loaded.Owner != "John" || loaded.Balance != 65 || loaded.Version() != 5 {
+			t.Errorf("expected John with 65 at version 5, got %s with %d at version %d", loaded.Owner, loaded.Balance, loaded.Version())
+		}
+
+		if store.loaded != 1 || store.loadedFrom[len(store.loadedFrom)-1] != 5 {
+			t.Errorf("expected to replay 1 event from version 5, replayed %d from %v", store.loaded, store.loadedFrom)
+		}
+	})
+
+	t.Run("snapshots on demand", func(t *testing.T) {
+		repo, store, _ := newSnapshotRepository(nil)
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+		account.Deposit(10)
+		repo.Save(ctx, account)
+
+		if err := repo.Snapshot(ctx, account); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		store.loaded = 0
+		loaded, _ := repo.Load(ctx, "acc-1")
+
+		if loaded.Balance != 10 || store.loaded != 0 {
+			t.Errorf("expected balance 10 without replay, got %d after %d events", loaded.Balance, store.loaded)
+		}
+
+		loaded.Deposit(1)
+		if err := repo.Save(ctx, loaded); err != nil {
+			t.Errorf("expected save after snapshot restore, got %v", err)
+		}
+	})
+
+	t.Run("ignores snapshots of another schema version", func(t *testing.T) {
+		repo, store, snapshots := newSnapshotRepository(nil)
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+		account.Deposit(10)
+		repo.Save(ctx, account)
+		snapshots.SaveSnapshot(ctx, Snapshot{AggregateID: "acc-1", Version: 2, SchemaVersion: 0, Data: []byte(`{"balance":999}`)})
+
+		store.loaded = 0
+		loaded, _ := repo.Load(ctx, "acc-1")
+
+		if loaded.Balance != 10 || store.loaded != 2 {
+			t.Errorf("expected full replay to balance 10, got %d after %d events", loaded.Balance, store.loaded)
+		}
+	})
+
+	t.Run("falls back to replay for unreadable snapshots", func(t *testing.T) {
+		repo, _, snapshots := newSnapshotRepository(nil)
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+		repo.Save(ctx, account)
+		snapshots.SaveSnapshot(ctx, Snapshot{AggregateID: "acc-1", Version: 1, SchemaVersion: 1, Data: []byte("not json")})
+
+		loaded, err := repo.Load(ctx, "acc-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if loaded.Owner != "John" || loaded.Version() != 1 {
+			t.Errorf("expected John at version 1, got %s at version %d", loaded.Owner, loaded.Version())
+		}
+	})
+
+	t.Run("timestamps snapshots with the repository clock", func(t *testing.T) {
+		repo, _, snapshots := newSnapshotRepository(SnapshotEvery(1))
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		repo.SetClock(clocktest.New(now))
+
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+		repo.Save(ctx, account)
+
+		snapshot, _ := snapshots.LoadSnapshot(ctx, "acc-1")
+		if snapshot == nil || !snapshot.Time.Equal(now) {
+			t.Errorf("expected snapshot at %v, got %v", now, snapshot)
+		}
+	})
+
+	t.Run("rejects snapshots with uncommitted events", func(t *testing.T) {
+		repo, _, _ := newSnapshotRepository(nil)
+		account := NewSnapshotAccount("")
+		account.Open("acc-1", "John")
+
+		if err := repo.Snapshot(ctx, account); err == nil {
+			t.Error("expected error for uncommitted events, got nil")
+		}
+	})
+}
+
+func TestInMemorySnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySnapshotStore()
+	store.SaveSnapshot(ctx, Snapshot{AggregateID: "acc-1", Version: 5})
+	store.SaveSnapshot(ctx, Snapshot{AggregateID: "acc-1", Version: 3})
+
+	snapshot, err := store.LoadSnapshot(ctx, "acc-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if snapshot.Version != 5 {
+		t.Errorf("expected to keep version 5, got %d", snapshot.Version)
+	}
+
+	if missing, _ := store.LoadSnapshot(ctx, "acc-2"); missing != nil {
+		t.Errorf("expected nil snapshot, got %v", missing)
+	}
+}
@@ -0,0 +1,259 @@
+package gocmdevt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQLDialect holds the database specific parts of SQLEventStore and SQLOutbox
+type SQLDialect struct {
+	// Schema is the CREATE TABLE statement; %[1]s is replaced by the table name
+	Schema string
+	// OutboxSchema is the CREATE TABLE statement of SQLOutbox
+	OutboxSchema string
+	// Placeholder returns the bind parameter for the n-th (1-based) argument
+	Placeholder func(n int) string
+	// IsUniqueViolation reports whether err is a unique constraint violation
+	IsUniqueViolation func(err error) bool
+}
+
+// PostgresDialect targets PostgreSQL.
+//
+//	CREATE TABLE IF NOT EXISTS events (
+//	    position      BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+//	    aggregate_id  TEXT        NOT NULL,
+//	    version       INTEGER     NOT NULL,
+//	    event_id      TEXT        NOT NULL,
+//	    event_type    TEXT        NOT NULL,
+//	    event_version INTEGER     NOT NULL,
+//	    occurred_at   TIMESTAMPTZ NOT NULL,
+//	    data          TEXT        NOT NULL,
+//	    UNIQUE (aggregate_id, version)
+//	)
+var PostgresDialect = SQLDialect{
+	Schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	position      BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	aggregate_id  TEXT        NOT NULL,
+	version       INTEGER     NOT NULL,
+	event_id      TEXT        NOT NULL,
+	event_type    TEXT        NOT NULL,
+	event_version INTEGER     NOT NULL,
+	occurred_at   TIMESTAMPTZ NOT NULL,
+	data          TEXT        NOT NULL,
+	UNIQUE (aggregate_id, version)
+)`,
+	OutboxSchema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id           BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	event_id     TEXT        NOT NULL,
+	data         TEXT        NOT NULL,
+	staged_at    TIMESTAMPTZ NOT NULL,
+	attempts     INTEGER     NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	delivered_at TIMESTAMPTZ,
+	abandoned_at TIMESTAMPTZ
+)`,
+	Placeholder:       func(n int) string { return fmt.Sprintf("$%d", n) },
+	IsUniqueViolation: isUniqueViolation,
+}
+
+// SQLiteDialect targets SQLite. The schema matches PostgresDialect with
+// position as an INTEGER PRIMARY KEY AUTOINCREMENT.
+var SQLiteDialect = SQLDialect{
+	Schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	position      INTEGER PRIMARY KEY AUTOINCREMENT,
+	aggregate_id  TEXT     NOT NULL,
+	version       INTEGER  NOT NULL,
+	event_id      TEXT     NOT NULL,
+	event_type    TEXT     NOT NULL,
+	event_version INTEGER  NOT NULL,
+	occurred_at   DATETIME NOT NULL,
+	data          TEXT     NOT NULL,
+	UNIQUE (aggregate_id, version)
+)`,
+	OutboxSchema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id     TEXT     NOT NULL,
+	data         TEXT     NOT NULL,
+	staged_at    DATETIME NOT NULL,
+	attempts     INTEGER  NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	delivered_at DATETIME,
+	abandoned_at DATETIME
+)`,
+	Placeholder:       func(int) string { return "?" },
+	IsUniqueViolation: isUniqueViolation,
+}
+
+// isUniqueViolation recognizes the messages of common drivers
+func isUniqueViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique") || strings.Contains(msg, "duplicate")
+}
+
+// SQLEventStoreConfig configures a SQLEventStore
+type SQLEventStoreConfig struct {
+	// Dialect defaults to PostgresDialect
+	Dialect *SQLDialect
+	// Table defaults to "events"
+	Table string
+	// Codec encodes the data column; defaults to JSONCodec
+	Codec EventCodec
+}
+
+// SQLEventStore is an EventStore in a database/sql table. The global
+// position is the table's sequence and the unique (aggregate_id, version)
+// constraint backs optimistic concurrency. It also implements EventLogWriter.
+type SQLEventStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+	codec   EventCodec
+}
+
+func NewSQLEventStore(db *sql.DB, config SQLEventStoreConfig) *SQLEventStore {
+	if config.Dialect == nil {
+		config.Dialect = &PostgresDialect
+	}
+	if config.Table == "" {
+		config.Table = "events"
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+	return &SQLEventStore{
+		db:      db,
+		dialect: *config.Dialect,
+		table:   config.Table,
+		codec:   config.Codec,
+	}
+}
+
+// CreateSchema creates the events table if it does not exist.
+func (s *SQLEventStore) CreateSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(s.dialect.Schema, s.table)); err != nil {
+		return fmt.Errorf("create event store schema: %w", err)
+	}
+	return nil
+}
+
+// Append adds events in a transaction of its own, or in the one carried by
+// ctx (see ContextWithTx), leaving the commit to its owner.
+func (s *SQLEventStore) Append(ctx context.Context, aggregateID string, expectedVersion int, events ...Event) (int, error) {
+	if tx := txFromContext(ctx); tx != nil {
+		return s.append(ctx, tx, aggregateID, expectedVersion, events)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin append: %w", err)
+	}
+	defer tx.Rollback()
+
+	version, err := s.append(ctx, tx, aggregateID, expectedVersion, events)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		if s.dialect.IsUniqueViolation != nil && s.dialect.IsUniqueViolation(err) {
+			return 0, &ConcurrencyError{AggregateID: aggregateID, Expected: expectedVersion, Actual: version - len(events) + 1}
+		}
+		return 0, fmt.Errorf("commit append: %w", err)
+	}
+	return version, nil
+}
+
+func (s *SQLEventStore) append(ctx context.Context, tx *sql.Tx, aggregateID string, expectedVersion int, events []Event) (int, error) {
+	var current int
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s WHERE aggregate_id = %s", s.table, s.dialect.Placeholder(1))
+	if err := tx.QueryRowContext(ctx, query, aggregateID).Scan(&current); err != nil {
+		return 0, fmt.Errorf("read aggregate version: %w", err)
+	}
+	if err := checkAppend(aggregateID, expectedVersion, current, events); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return current, nil
+	}
+
+	// Insert the whole batch with a single statement
+	const columns = 7
+	values := make([]string, len(events))
+	args := make([]any, 0, len(events)*columns)
+	for i, event := range events {
+		data, err := s.codec.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = s.dialect.Placeholder(i*columns + j + 1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args,
+			aggregateID, current+i+1, event.EventID(), event.EventType(),
+			event.EventVersion(), event.EventTime(), string(data))
+	}
+
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (aggregate_id, version, event_id, event_type, event_version, occurred_at, data) VALUES %s",
+		s.table, strings.Join(values, ", "))
+	if _, err := tx.ExecContext(ctx, insert, args...); err != nil {
+		if s.dialect.IsUniqueViolation != nil && s.dialect.IsUniqueViolation(err) {
+			// Another writer appended between our read and insert
+			return 0, &ConcurrencyError{AggregateID: aggregateID, Expected: expectedVersion, Actual: current + 1}
+		}
+		return 0, fmt.Errorf("insert events: %w", err)
+	}
+	return current + len(events), nil
+}
+
+// Write appends event to its aggregate without a version check.
+func (s *SQLEventStore) Write(event Event) error {
+	_, err := s.Append(context.Background(), event.AggregateID(), AnyVersion, event)
+	return err
+}
+
+func (s *SQLEventStore) Load(ctx context.Context, aggregateID string, fromVersion int) ([]StoredEvent, error) {
+	query := fmt.Sprintf(
+		"SELECT position, version, data FROM %s WHERE aggregate_id = %s AND version >= %s ORDER BY version",
+		s.table, s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+	return s.query(ctx, query, aggregateID, fromVersion)
+}
+
+func (s *SQLEventStore) LoadAll(ctx context.Context, fromPosition int64, limit int) ([]StoredEvent, error) {
+	query := fmt.Sprintf(
+		"SELECT position, version, data FROM %s WHERE position >= %s ORDER BY position",
+		s.table, s.dialect.Placeholder(1))
+	args := []any{fromPosition}
+	if limit > 0 {
+		query += " LIMIT " + s.dialect.Placeholder(2)
+		args = append(args, limit)
+	}
+	return s.query(ctx, query, args...)
+}
+
+func (s *SQLEventStore) query(ctx context.Context, query string, args ...any) ([]StoredEvent, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	var stored []StoredEvent
+	for rows.Next() {
+		var (
+			se   StoredEvent
+			data string
+		)
+		if err := rows.Scan(&se.Position, &se.Version, &data); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		if se.Event, err = s.codec.Unmarshal([]byte(data)); err != nil {
+			return nil, err
+		}
+		stored = append(stored, se)
+	}
+	return stored, rows.Err()
+}
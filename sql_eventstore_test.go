@@ -0,0 +1,338 @@
+package gocmdevt_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gocmdevt "github.com/leviplj/go-cmd-evt"
+	"github.com/leviplj/go-cmd-evt/eventstoretest"
+)
+
+// openMemDB opens a fresh memsql database
+func openMemDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("memsql", fmt.Sprintf("db-%d", memDBCounter.Add(1)))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newSQLStore(t *testing.T) *gocmdevt.SQLEventStore {
+	t.Helper()
+	return newSQLStoreIn(t, openMemDB(t))
+}
+
+func newSQLStoreIn(t *testing.T, db *sql.DB) *gocmdevt.SQLEventStore {
+	t.Helper()
+	store := gocmdevt.NewSQLEventStore(db, gocmdevt.SQLEventStoreConfig{Dialect: &gocmdevt.SQLiteDialect})
+	if err := store.CreateSchema(context.Background()); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return store
+}
+
+func TestSQLEventStore(t *testing.T) {
+	eventstoretest.Run(t, func(t *testing.T) gocmdevt.EventStore {
+		return newSQLStore(t)
+	})
+}
+
+func TestSQLEventStore_BatchAppend(t *testing.T) {
+	store := newSQLStore(t)
+	ctx := context.Background()
+	events := make([]gocmdevt.Event, 5)
+	for i := range events {
+		events[i] = eventstoretest.NewNoteAdded("note-1", fmt.Sprint(i))
+	}
+
+	version, err := store.Append(ctx, "note-1", gocmdevt.NoStream, events...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != 5 {
+		t.Errorf("expected version 5, got %d", version)
+	}
+
+	if err := store.Write(eventstoretest.NewNoteAdded("note-1", "5")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stored, _ := store.Load(ctx, "note-1", 5)
+	if len(stored) != 2 || stored[1].Version != 6 {
+		t.Errorf("expected versions 5 and 6, got %v", stored)
+	}
+}
+
+func TestPostgresDialect_Placeholder(t *testing.T) {
+	if got := gocmdevt.PostgresDialect.Placeholder(3); got != "$3" {
+		t.Errorf("expected $3, got %s", got)
+	}
+}
+
+// memsql is a stand-in database/sql driver that understands only the
+// statements issued by SQLEventStore and SQLOutbox, with the outbox in a
+// table named "outbox". Transactions buffer their inserts and check the
+// unique (aggregate_id, version) constraint again on commit, so concurrent
+// appends fail the way they do in a real database.
+
+var memDBCounter atomic.Int64
+
+func init() {
+	sql.Register("memsql", &memDriver{dbs: map[string]*memDB{}})
+}
+
+type memDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*memDB
+}
+
+func (d *memDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[name]
+	if !ok {
+		db = &memDB{}
+		d.dbs[name] = db
+	}
+	return &memConn{db: db}, nil
+}
+
+type memRow struct {
+	position    int64
+	aggregateID string
+	version     int64
+	data        string
+}
+
+type memOutboxRow struct {
+	id        int64
+	data      string
+	stagedAt  time.Time
+	attempts  int64
+	lastError string
+	delivered bool
+	abandoned bool
+}
+
+type memDB struct {
+	mu     sync.Mutex
+	rows   []memRow
+	outbox []memOutboxRow
+}
+
+// insert adds rows after checking the unique constraint; the caller holds mu
+func (db *memDB) insert(rows []memRow) error {
+	for _, r := range rows {
+		for _, existing := range db.rows {
+			if existing.aggregateID == r.aggregateID && existing.version == r.version {
+				return errors.New("UNIQUE constraint failed: events.aggregate_id, events.version")
+			}
+		}
+	}
+	for _, r := range rows {
+		r.position = int64(len(db.rows) + 1)
+		db.rows = append(db.rows, r)
+	}
+	return nil
+}
+
+type memConn struct {
+	db            *memDB
+	pending       []memRow // inserts of the open transaction
+	pendingOutbox []memOutboxRow
+	inTx          bool
+}
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	return &memStmt{conn: c, query: query}, nil
+}
+
+func (c *memConn) Close() error { return nil }
+
+func (c *memConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	c.pending, c.pendingOutbox = nil, nil
+	return c, nil
+}
+
+func (c *memConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.inTx = false
+	rows, outbox := c.pending, c.pendingOutbox
+	c.pending, c.pendingOutbox = nil, nil
+	if err := c.db.insert(rows); err != nil {
+		return err
+	}
+	c.db.stage(outbox)
+	return nil
+}
+
+func (c *memConn) Rollback() error {
+	c.inTx = false
+	c.pending, c.pendingOutbox = nil, nil
+	return nil
+}
+
+// stage adds outbox rows; the caller holds mu
+func (db *memDB) stage(rows []memOutboxRow) {
+	for _, r := range rows {
+		r.id = int64(len(db.outbox) + 1)
+		db.outbox = append(db.outbox, r)
+	}
+}
+
+// outboxRow returns the outbox row with id; the caller holds mu
+func (db *memDB) outboxRow(id driver.Value) (*memOutboxRow, error) {
+	n := id.(int64)
+	if n < 1 || n > int64(len(db.outbox)) {
+		return nil, fmt.Errorf("memsql: no outbox row %d", n)
+	}
+	return &db.outbox[n-1], nil
+}
+
+type memStmt struct {
+	conn  *memConn
+	query string
+}
+
+func (s *memStmt) Close() error  { return nil }
+func (s *memStmt) NumInput() int { return -1 }
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "INSERT INTO outbox"):
+		var rows []memOutboxRow
+		for i := 0; i+3 <= len(args); i += 3 {
+			rows = append(rows, memOutboxRow{data: args[i+1].(string), stagedAt: args[i+2].(time.Time)})
+		}
+		if s.conn.inTx {
+			s.conn.pendingOutbox = append(s.conn.pendingOutbox, rows...)
+			return driver.RowsAffected(len(rows)), nil
+		}
+		s.conn.db.mu.Lock()
+		defer s.conn.db.mu.Unlock()
+		s.conn.db.stage(rows)
+		return driver.RowsAffected(len(rows)), nil
+	case strings.HasPrefix(s.query, "UPDATE outbox SET delivered_at"):
+		s.conn.db.mu.Lock()
+		defer s.conn.db.mu.Unlock()
+		row, err := s.conn.db.outboxRow(args[1])
+		if err != nil {
+			return nil, err
+		}
+		row.delivered = true
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "UPDATE outbox SET attempts"):
+		s.conn.db.mu.Lock()
+		defer s.conn.db.mu.Unlock()
+		row, err := s.conn.db.outboxRow(args[2])
+		if err != nil {
+			return nil, err
+		}
+		row.attempts++
+		row.lastError = args[0].(string)
+		row.abandoned = args[1] != nil
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "INSERT INTO"):
+		var rows []memRow
+		for i := 0; i+7 <= len(args); i += 7 {
+			rows = append(rows, memRow{
+				aggregateID: args[i].(string),
+				version:     args[i+1].(int64),
+				data:        args[i+6].(string),
+			})
+		}
+		if s.conn.inTx {
+			s.conn.pending = append(s.conn.pending, rows...)
+			return driver.RowsAffected(len(rows)), nil
+		}
+		s.conn.db.mu.Lock()
+		defer s.conn.db.mu.Unlock()
+		return driver.RowsAffected(len(rows)), s.conn.db.insert(rows)
+	}
+	return nil, fmt.Errorf("memsql: unsupported exec %q", s.query)
+}
+
+func (s *memStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "SELECT id, data, staged_at"):
+		result := &memRows{columns: []string{"id", "data", "staged_at", "attempts", "last_error"}}
+		for _, r := range db.outbox {
+			if len(args) > 0 && int64(len(result.values)) >= args[0].(int64) {
+				break
+			}
+			if !r.delivered && !r.abandoned {
+				result.values = append(result.values, []driver.Value{r.id, r.data, r.stagedAt, r.attempts, r.lastError})
+			}
+		}
+		return result, nil
+
+	case strings.HasPrefix(s.query, "SELECT COALESCE(MAX(version), 0)"):
+		var max int64
+		for _, r := range db.rows {
+			if r.aggregateID == args[0].(string) && r.version > max {
+				max = r.version
+			}
+		}
+		return &memRows{columns: []string{"version"}, values: [][]driver.Value{{max}}}, nil
+
+	case strings.Contains(s.query, "WHERE aggregate_id ="):
+		result := &memRows{columns: []string{"position", "version", "data"}}
+		for _, r := range db.rows {
+			if r.aggregateID == args[0].(string) && r.version >= args[1].(int64) {
+				result.values = append(result.values, []driver.Value{r.position, r.version, r.data})
+			}
+		}
+		return result, nil
+
+	case strings.Contains(s.query, "WHERE position >="):
+		result := &memRows{columns: []string{"position", "version", "data"}}
+		for _, r := range db.rows {
+			if r.position < args[0].(int64) {
+				continue
+			}
+			if len(args) > 1 && int64(len(result.values)) >= args[1].(int64) {
+				break
+			}
+			result.values = append(result.values, []driver.Value{r.position, r.version, r.data})
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("memsql: unsupported query %q", s.query)
+}
+
+type memRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *memRows) Columns() []string { return r.columns }
+func (r *memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
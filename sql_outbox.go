@@ -0,0 +1,164 @@
+package gocmdevt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type sqlTxKey struct{}
+
+// ContextWithTx makes SQLEventStore and SQLOutbox run their statements in
+// tx, so events are logged and staged in the same transaction as the
+// caller's own changes and commit or roll back with them.
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, sqlTxKey{}, tx)
+}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(sqlTxKey{}).(*sql.Tx)
+	return tx
+}
+
+// SQLOutboxConfig configures a SQLOutbox
+type SQLOutboxConfig struct {
+	// Dialect defaults to PostgresDialect
+	Dialect *SQLDialect
+	// Table defaults to "outbox"
+	Table string
+	// Codec encodes the data column; defaults to JSONCodec. Use an
+	// EventRegistry to relay typed events.
+	Codec EventCodec
+	// Clock timestamps staging and delivery; defaults to DefaultClock
+	Clock Clock
+}
+
+// SQLOutbox is an Outbox in a database/sql table. Stage joins the
+// transaction carried by the context, see ContextWithTx.
+type SQLOutbox struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+	codec   EventCodec
+	clock   Clock
+}
+
+func NewSQLOutbox(db *sql.DB, config SQLOutboxConfig) *SQLOutbox {
+	if config.Dialect == nil {
+		config.Dialect = &PostgresDialect
+	}
+	if config.Table == "" {
+		config.Table = "outbox"
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+	return &SQLOutbox{
+		db:      db,
+		dialect: *config.Dialect,
+		table:   config.Table,
+		codec:   config.Codec,
+		clock:   clockOrDefault(config.Clock),
+	}
+}
+
+// CreateSchema creates the outbox table if it does not exist.
+func (o *SQLOutbox) CreateSchema(ctx context.Context) error {
+	if _, err := o.db.ExecContext(ctx, fmt.Sprintf(o.dialect.OutboxSchema, o.table)); err != nil {
+		return fmt.Errorf("create outbox schema: %w", err)
+	}
+	return nil
+}
+
+func (o *SQLOutbox) Stage(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	const columns = 3
+	now := o.clock.Now().UTC()
+	values := make([]string, len(events))
+	args := make([]any, 0, len(events)*columns)
+	for i, event := range events {
+		data, err := o.codec.Marshal(event)
+		if err != nil {
+			return err
+		}
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = o.dialect.Placeholder(i*columns + j + 1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, event.EventID(), string(data), now)
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (event_id, data, staged_at) VALUES %s", o.table, strings.Join(values, ", "))
+	if _, err := o.exec(ctx, insert, args...); err != nil {
+		return fmt.Errorf("insert outbox entries: %w", err)
+	}
+	return nil
+}
+
+func (o *SQLOutbox) Pending(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	query := fmt.Sprintf(
+		"SELECT id, data, staged_at, attempts, COALESCE(last_error, '') FROM %s WHERE delivered_at IS NULL AND abandoned_at IS NULL ORDER BY id",
+		o.table)
+	var args []any
+	if limit > 0 {
+		query += " LIMIT " + o.dialect.Placeholder(1)
+		args = append(args, limit)
+	}
+
+	rows, err := o.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var (
+			entry OutboxEntry
+			data  string
+		)
+		if err := rows.Scan(&entry.ID, &data, &entry.StagedAt, &entry.Attempts, &entry.LastError); err != nil {
+			return nil, fmt.Errorf("scan outbox entry: %w", err)
+		}
+		if entry.Event, err = o.codec.Unmarshal([]byte(data)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (o *SQLOutbox) MarkDelivered(ctx context.Context, id int64) error {
+	update := fmt.Sprintf("UPDATE %s SET delivered_at = %s WHERE id = %s",
+		o.table, o.dialect.Placeholder(1), o.dialect.Placeholder(2))
+	if _, err := o.exec(ctx, update, o.clock.Now().UTC(), id); err != nil {
+		return fmt.Errorf("mark outbox entry %d delivered: %w", id, err)
+	}
+	return nil
+}
+
+func (o *SQLOutbox) MarkFailed(ctx context.Context, id int64, cause error, abandon bool) error {
+	var abandonedAt any
+	if abandon {
+		abandonedAt = o.clock.Now().UTC()
+	}
+	update := fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, last_error = %s, abandoned_at = %s WHERE id = %s",
+		o.table, o.dialect.Placeholder(1), o.dialect.Placeholder(2), o.dialect.Placeholder(3))
+	if _, err := o.exec(ctx, update, cause.Error(), abandonedAt, id); err != nil {
+		return fmt.Errorf("mark outbox entry %d failed: %w", id, err)
+	}
+	return nil
+}
+
+// exec runs a statement in ctx's transaction, if any
+func (o *SQLOutbox) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx.ExecContext(ctx, query, args...)
+	}
+	return o.db.ExecContext(ctx, query, args...)
+}
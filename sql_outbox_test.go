@@ -0,0 +1,123 @@
+package gocmdevt_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	gocmdevt "github.com/leviplj/go-cmd-evt"
+	"github.com/leviplj/go-cmd-evt/eventstoretest"
+)
+
+// newSQLOutboxEmitter returns an emitter that logs to a SQL event store and
+// stages in a SQL outbox of the same database
+func newSQLOutboxEmitter(t *testing.T) (*sql.DB, *gocmdevt.SQLEventStore, *gocmdevt.SQLOutbox, *gocmdevt.EventEmitter) {
+	t.Helper()
+	db := openMemDB(t)
+	store := newSQLStoreIn(t, db)
+
+	registry := gocmdevt.NewEventRegistry()
+	gocmdevt.RegisterEvent[*eventstoretest.NoteAdded](registry, "NoteAdded", 1)
+	outbox := gocmdevt.NewSQLOutbox(db, gocmdevt.SQLOutboxConfig{Dialect: &gocmdevt.SQLiteDialect, Codec: registry})
+	if err := outbox.CreateSchema(context.Background()); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	emitter := gocmdevt.NewEventEmitter(store, gocmdevt.NewInMemoryDispatcher())
+	emitter.Outbox = outbox
+	return db, store, outbox, emitter
+}
+
+func TestSQLOutbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commits events with the transaction", func(t *testing.T) {
+		db, store, outbox, emitter := newSQLOutboxEmitter(t)
+
+		tx, _ := db.BeginTx(ctx, nil)
+		txCtx := gocmdevt.ContextWithTx(ctx, tx)
+		if err := emitter.EmitCtx(txCtx, eventstoretest.NewNoteAdded("note-1", "a")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Fatalf("expected nothing pending before commit, got %d", len(pending))
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+
+		pending, err := outbox.Pending(ctx, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(pending) != 1 {
+			t.Fatalf("expected 1 pending entry, got %d", len(pending))
+		}
+		if note, ok := pending[0].Event.(*eventstoretest.NoteAdded); !ok || note.Text != "a" {
+			t.Errorf("expected NoteAdded a, got %#v", pending[0].Event)
+		}
+		if stored, _ := store.Load(ctx, "note-1", 1); len(stored) != 1 {
+			t.Errorf("expected 1 stored event, got %d", len(stored))
+		}
+	})
+
+	t.Run("discards events of a rolled back transaction", func(t *testing.T) {
+		db, store, outbox, emitter := newSQLOutboxEmitter(t)
+
+		tx, _ := db.BeginTx(ctx, nil)
+		emitter.EmitCtx(gocmdevt.ContextWithTx(ctx, tx), eventstoretest.NewNoteAdded("note-1", "a"))
+		tx.Rollback()
+
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Errorf("expected nothing pending, got %d", len(pending))
+		}
+		if stored, _ := store.Load(ctx, "note-1", 1); len(stored) != 0 {
+			t.Errorf("expected no stored events, got %d", len(stored))
+		}
+	})
+
+	t.Run("relays committed events and records delivery", func(t *testing.T) {
+		_, _, outbox, emitter := newSQLOutboxEmitter(t)
+		dispatcher := gocmdevt.NewInMemoryDispatcher()
+		var delivered []string
+		dispatcher.Subscribe(&eventstoretest.NoteAdded{}, func(ctx context.Context, e gocmdevt.Event) (any, error) {
+			delivered = append(delivered, e.(*eventstoretest.NoteAdded).Text)
+			return nil, nil
+		})
+
+		emitter.EmitCtx(ctx, eventstoretest.NewNoteAdded("note-1", "a"))
+		emitter.EmitCtx(ctx, eventstoretest.NewNoteAdded("note-2", "b"))
+
+		relay := gocmdevt.NewOutboxRelay(outbox, dispatcher, gocmdevt.OutboxRelayConfig{})
+		n, err := relay.RelayPending(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if n != 2 || len(delivered) != 2 || delivered[0] != "a" || delivered[1] != "b" {
+			t.Errorf("expected a and b delivered, got %d: %v", n, delivered)
+		}
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Errorf("expected nothing pending, got %d", len(pending))
+		}
+	})
+
+	t.Run("records failed attempts", func(t *testing.T) {
+		_, _, outbox, _ := newSQLOutboxEmitter(t)
+		outbox.Stage(ctx, eventstoretest.NewNoteAdded("note-1", "a"))
+
+		outbox.MarkFailed(ctx, 1, errors.New("broker down"), false)
+		pending, _ := outbox.Pending(ctx, 0)
+		if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError != "broker down" {
+			t.Fatalf("expected 1 failed attempt, got %+v", pending)
+		}
+
+		outbox.MarkFailed(ctx, 1, errors.New("broker down"), true)
+		if pending, _ := outbox.Pending(ctx, 0); len(pending) != 0 {
+			t.Errorf("expected abandoned entry to leave pending, got %d", len(pending))
+		}
+	})
+}
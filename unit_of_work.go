@@ -0,0 +1,108 @@
+package gocmdevt
+
+import (
+	"context"
+	"sync"
+)
+
+// UnitOfWork returns middleware that runs each command in a unit of work.
+// Events emitted through an EventEmitter while the handler runs are
+// buffered in the context, and only logged and dispatched, in emission
+// order, once the handler returns a nil error. If the handler fails or
+// panics they are discarded.
+//
+// Install it for every command with app.Use(gocmdevt.UnitOfWork()), or for
+// some with UseFor. Commands handled from within a unit join it, so their
+// events are kept or discarded together with the outer command's.
+//
+// A failure while emitting the buffered events is returned from Handle.
+// Events emitted before it are not rolled back.
+func UnitOfWork() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, cmd Command) (any, error) {
+			parent := unitFromContext(ctx)
+			unit := &unitOfWork{}
+			defer func() { discardEntries(unit.close()) }() // if next panics
+
+			result, err := next(context.WithValue(ctx, unitOfWorkKey{}, unit), cmd)
+			pending := unit.close()
+			if err != nil {
+				discardEntries(pending)
+				return result, err
+			}
+
+			// Nested units hand their events to the enclosing one
+			if parent != nil && parent.buffer(pending...) {
+				return result, nil
+			}
+			for i, entry := range pending {
+				if err := entry.emit(); err != nil {
+					discardEntries(pending[i+1:])
+					return result, err
+				}
+			}
+			return result, nil
+		}
+	}
+}
+
+type unitOfWorkKey struct{}
+
+// unitOfWork buffers emissions until its command completes
+type unitOfWork struct {
+	mu      sync.Mutex
+	pending []unitEntry
+	closed  bool
+}
+
+// unitEntry is a buffered emission. discard, when set, runs instead of emit
+// if the unit drops it.
+type unitEntry struct {
+	emit    func() error
+	discard func()
+}
+
+// discardEntries runs the discard hooks of entries that will not be emitted
+func discardEntries(entries []unitEntry) {
+	for _, entry := range entries {
+		if entry.discard != nil {
+			entry.discard()
+		}
+	}
+}
+
+func unitFromContext(ctx context.Context) *unitOfWork {
+	unit, _ := ctx.Value(unitOfWorkKey{}).(*unitOfWork)
+	return unit
+}
+
+// withoutUnit returns ctx detached from its unit of work, for emitting
+// buffered events and for what their handlers do in turn
+func withoutUnit(ctx context.Context) context.Context {
+	if unitFromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, unitOfWorkKey{}, (*unitOfWork)(nil))
+}
+
+// buffer holds emissions until the unit completes, reporting false once it
+// has, in which case the caller emits right away
+func (u *unitOfWork) buffer(entries ...unitEntry) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return false
+	}
+	u.pending = append(u.pending, entries...)
+	return true
+}
+
+// close ends buffering and returns what was buffered
+func (u *unitOfWork) close() []unitEntry {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	pending := u.pending
+	u.pending = nil
+	return pending
+}
@@ -0,0 +1,255 @@
+package gocmdevt
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type failingCmd struct{ ID string }
+
+type panickingCmd struct{ ID string }
+
+type nestingCmd struct{ ID string }
+
+// newUnitOfWorkApp extends newCausalityApp with commands that emit and then
+// fail, panic, or handle createUserCmd and fail, all in a unit of work
+func newUnitOfWorkApp(t *testing.T) (*App, *recordingLogWriter, *EventEmitter) {
+	t.Helper()
+	app, logWriter := newCausalityApp(t)
+	emitter := NewEventEmitter(logWriter, NewInMemoryDispatcher())
+	errFailed := errors.New("command failed")
+
+	Register(app, func(ctx context.Context, cmd *failingCmd) (any, error) {
+		emitter.EmitCtx(ctx, NewUserCreatedEvent(cmd.ID, "John"))
+		return nil, errFailed
+	})
+	Register(app, func(ctx context.Context, cmd *panickingCmd) (any, error) {
+		emitter.EmitCtx(ctx, NewUserCreatedEvent(cmd.ID, "John"))
+		panic("handler panicked")
+	})
+	Register(app, func(ctx context.Context, cmd *nestingCmd) (any, error) {
+		if _, err := app.Handle(ctx, &createUserCmd{ID: cmd.ID}); err != nil {
+			return nil, err
+		}
+		return nil, errFailed
+	})
+	app.Use(UnitOfWork())
+	return app, logWriter, emitter
+}
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("emits events after the handler succeeds", func(t *testing.T) {
+		app, logWriter, _ := newUnitOfWorkApp(t)
+
+		if _, err := app.Handle(ctx, &createUserCmd{ID: "user-1"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// UserCreatedEvent triggers deleteUserCmd, which runs in a unit of its own
+		if len(logWriter.events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(logWriter.events))
+		}
+		created := logWriter.events[0].(*UserCreatedEvent)
+		deleted := logWriter.events[1].(*UserDeletedEvent)
+		if deleted.CausationID() != created.EventID() {
+			t.Errorf("expected causation %s, got %s", created.EventID(), deleted.CausationID())
+		}
+	})
+
+	t.Run("buffers events until the handler returns", func(t *testing.T) {
+		app, logWriter, emitter := newUnitOfWorkApp(t)
+		var logged int
+		Register(app, func(ctx context.Context, cmd *renameUserCmd) (any, error) {
+			emitter.EmitCtx(ctx, NewUserCreatedEvent("user-1", "John"))
+			logged = len(logWriter.events)
+			return nil, nil
+		})
+
+		app.Handle(ctx, &renameUserCmd{})
+
+		if logged != 0 {
+			t.Errorf("expected no events logged during the handler, got %d", logged)
+		}
+		if len(logWriter.events) != 1 {
+			t.Errorf("expected 1 event after the handler, got %d", len(logWriter.events))
+		}
+	})
+
+	t.Run("discards events when the handler fails", func(t *testing.T) {
+		app, logWriter, _ := newUnitOfWorkApp(t)
+
+		if _, err := app.Handle(ctx, &failingCmd{ID: "user-1"}); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if len(logWriter.events) != 0 {
+			t.Errorf("expected no events, got %d", len(logWriter.events))
+		}
+	})
+
+	t.Run("discards events when the handler panics", func(t *testing.T) {
+		app, logWriter, _ := newUnitOfWorkApp(t)
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			app.Handle(ctx, &panickingCmd{ID: "user-1"})
+		}()
+		if len(logWriter.events) != 0 {
+			t.Errorf("expected no events, got %d", len(logWriter.events))
+		}
+	})
+
+	t.Run("discards events of nested commands with the outer one", func(t *testing.T) {
+		app, logWriter, _ := newUnitOfWorkApp(t)
+
+		if _, err := app.Handle(ctx, &nestingCmd{ID: "user-1"}); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		if len(logWriter.events) != 0 {
+			t.Errorf("expected no events, got %d", len(logWriter.events))
+		}
+	})
+
+	t.Run("returns emit failures from Handle", func(t *testing.T) {
+		app, _, emitter := newUnitOfWorkApp(t)
+		errLog := errors.New("disk full")
+		Register(app, func(ctx context.Context, cmd *renameUserCmd) (any, error) {
+			return nil, emitter.EmitVersioned(ctx, "user-1", AnyVersion, NewUserCreatedEvent("user-1", "John"))
+		})
+		emitter.LogWriter = &recordingLogWriter{err: errLog}
+
+		if _, err := app.Handle(ctx, &renameUserCmd{}); !errors.Is(err, errLog) {
+			t.Errorf("expected %v, got %v", errLog, err)
+		}
+	})
+
+	t.Run("emits right away once the unit has ended", func(t *testing.T) {
+		app, logWriter, emitter := newUnitOfWorkApp(t)
+		var unitCtx context.Context
+		Register(app, func(ctx context.Context, cmd *renameUserCmd) (any, error) {
+			unitCtx = ctx
+			return nil, nil
+		})
+
+		app.Handle(ctx, &renameUserCmd{})
+		emitter.EmitCtx(unitCtx, NewUserCreatedEvent("user-1", "John"))
+
+		if len(logWriter.events) != 1 {
+			t.Errorf("expected 1 event, got %d", len(logWriter.events))
+		}
+	})
+
+	t.Run("does not apply without the middleware", func(t *testing.T) {
+		app, logWriter := newCausalityApp(t)
+		emitter := NewEventEmitter(logWriter, NewInMemoryDispatcher())
+		Register(app, func(ctx context.Context, cmd *failingCmd) (any, error) {
+			emitter.EmitCtx(ctx, NewUserCreatedEvent(cmd.ID, "John"))
+			return nil, errors.New("command failed")
+		})
+
+		app.Handle(ctx, &failingCmd{ID: "user-1"})
+
+		if len(logWriter.events) != 1 {
+			t.Errorf("expected 1 event, got %d", len(logWriter.events))
+		}
+	})
+}
+
+type depositCmd struct {
+	Amount int
+	Saves  int
+	Fail   bool
+}
+
+func TestUnitOfWork_Repository(t *testing.T) {
+	ctx := context.Background()
+	errFailed := errors.New("command failed")
+
+	// newDepositApp opens acc-1 and registers depositCmd, which deposits
+	// and saves Saves times in a unit of work, then fails if asked to
+	newDepositApp := func(t *testing.T) (*App, *Repository[*SnapshotAccount], *countingEventStore, *InMemorySnapshotStore, **SnapshotAccount) {
+		t.Helper()
+		repo, store, snapshots := newSnapshotRepository(SnapshotEvery(1))
+		opened := NewSnapshotAccount("")
+		opened.Open("acc-1", "John")
+		if err := repo.Save(ctx, opened); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		app := NewApp()
+		var account *SnapshotAccount
+		Register(app, func(ctx context.Context, cmd *depositCmd) (any, error) {
+			loaded, err := repo.Load(ctx, "acc-1")
+			if err != nil {
+				return nil, err
+			}
+			account = loaded
+			for i := 0; i < cmd.Saves; i++ {
+				account.Deposit(cmd.Amount)
+				if err := repo.Save(ctx, account); err != nil {
+					return nil, err
+				}
+			}
+			if cmd.Fail {
+				return nil, errFailed
+			}
+			return nil, nil
+		})
+		app.Use(UnitOfWork())
+		return app, repo, store, snapshots, &account
+	}
+
+	t.Run("does not commit or snapshot when the command fails", func(t *testing.T) {
+		app, repo, store, snapshots, account := newDepositApp(t)
+
+		if _, err := app.Handle(ctx, &depositCmd{Amount: 100, Saves: 1, Fail: true}); !errors.Is(err, errFailed) {
+			t.Fatalf("expected %v, got %v", errFailed, err)
+		}
+
+		if stored, _ := store.InMemoryEventStore.Load(ctx, "acc-1", 1); len(stored) != 1 {
+			t.Errorf("expected 1 stored event, got %d", len(stored))
+		}
+		if snapshot, _ := snapshots.LoadSnapshot(ctx, "acc-1"); snapshot == nil || snapshot.Version != 1 {
+			t.Errorf("expected the snapshot to stay at version 1, got %v", snapshot)
+		}
+		if n := len((*account).Uncommitted()); n != 1 {
+			t.Errorf("expected the deposit to stay uncommitted, got %d events", n)
+		}
+
+		loaded, err := repo.Load(ctx, "acc-1")
+		if err != nil || loaded.Balance != 0 || loaded.Version() != 1 {
+			t.Fatalf("expected balance 0 at version 1, got %d at version %d, %v", loaded.Balance, loaded.Version(), err)
+		}
+		loaded.Deposit(5)
+		if err := repo.Save(ctx, loaded); err != nil {
+			t.Errorf("expected later saves to succeed, got %v", err)
+		}
+	})
+
+	t.Run("commits and snapshots once the command succeeds", func(t *testing.T) {
+		app, repo, store, snapshots, account := newDepositApp(t)
+
+		if _, err := app.Handle(ctx, &depositCmd{Amount: 10, Saves: 2}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if stored, _ := store.InMemoryEventStore.Load(ctx, "acc-1", 1); len(stored) != 3 {
+			t.Errorf("expected 3 stored events, got %d", len(stored))
+		}
+		if n := len((*account).Uncommitted()); n != 0 {
+			t.Errorf("expected no uncommitted events, got %d", n)
+		}
+		if snapshot, _ := snapshots.LoadSnapshot(ctx, "acc-1"); snapshot == nil || snapshot.Version != 3 {
+			t.Errorf("expected a snapshot at version 3, got %v", snapshot)
+		}
+		if loaded, _ := repo.Load(ctx, "acc-1"); loaded.Balance != 20 || loaded.Version() != 3 {
+			t.Errorf("expected balance 20 at version 3, got %d at version %d", loaded.Balance, loaded.Version())
+		}
+	})
+}